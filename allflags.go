@@ -0,0 +1,79 @@
+package features
+
+import (
+	"strings"
+
+	"github.com/altipla-consulting/env"
+)
+
+// AllFlags evaluates every known flag of the default client with the options in a
+// single call, to send the state of the flags to a frontend on page load. Known
+// flags are the ones received from the server, the ones declared with Register or
+// Define and the ones with overrides. The evaluations are not registered in the
+// stats, because the frontend may not use all of them.
+func AllFlags(opts ...FlagOption) map[string]bool {
+	if DefaultClient == nil {
+		flags := make(map[string]bool)
+		for _, code := range registeredFlags() {
+			flags[code] = env.IsLocal()
+		}
+		return flags
+	}
+	return DefaultClient.AllFlags(opts...)
+}
+
+// AllFlags evaluates every known flag of the client with the options in a single
+// call. See AllFlags for the details.
+func (c *Client) AllFlags(opts ...FlagOption) map[string]bool {
+	o := newFlagOptions(opts)
+
+	var flags []flagReply
+	if !c.local {
+		c.access()
+
+		c.mu.RLock()
+		flags, _ = c.usableFlags()
+		c.mu.RUnlock()
+	}
+
+	all := make(map[string]bool)
+	evaluate := func(code string) {
+		if _, ok := all[code]; ok {
+			return
+		}
+		if detail, ok := c.override(code, o.tenant); ok {
+			all[code] = detail.Enabled
+			return
+		}
+		if c.local {
+			all[code] = true
+			return
+		}
+		all[code] = c.evaluate(flags, code, o.tenant, o.user, o.attributes).Enabled
+	}
+	for _, f := range flags {
+		evaluate(f.Code)
+	}
+	for _, code := range registeredFlags() {
+		evaluate(code)
+	}
+	for _, code := range c.overriddenFlags() {
+		evaluate(code)
+	}
+	return all
+}
+
+// overriddenFlags returns the codes of the flags with overrides in any layer.
+func (c *Client) overriddenFlags() []string {
+	c.overridesMu.RLock()
+	defer c.overridesMu.RUnlock()
+
+	var codes []string
+	for _, overrides := range c.overrides {
+		for key := range overrides {
+			code, _, _ := strings.Cut(key, "@")
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,59 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/altipla-consulting/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllFlags(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	initFlags()
+	DefaultClient.statsCh = make(chan accessEvent, 10)
+	Define("declared", true)
+	DefaultClient.Override("overridden", true, WithTenant("foo-tenant"))
+
+	require.Equal(t, map[string]bool{
+		"global-enabled":                 true,
+		"global-disabled":                false,
+		"tenant-enabled":                 true,
+		"tenant-disabled":                false,
+		"global-disabled-tenant-enabled": false,
+		"declared":                       true,
+		"overridden":                     true,
+	}, AllFlags(WithTenant("foo-tenant")))
+
+	require.Equal(t, map[string]bool{
+		"global-enabled":                 true,
+		"global-disabled":                false,
+		"tenant-enabled":                 false,
+		"tenant-disabled":                false,
+		"global-disabled-tenant-enabled": false,
+		"declared":                       true,
+		"overridden":                     false,
+	}, AllFlags(WithTenant("bar-tenant")))
+
+	require.Empty(t, DefaultClient.statsCh)
+}
+
+func TestAllFlagsLocal(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	initFlags()
+	DefaultClient.local = true
+	Register("declared")
+	DefaultClient.Override("overridden", false)
+
+	require.Equal(t, map[string]bool{
+		"declared":   true,
+		"overridden": false,
+	}, AllFlags())
+}
+
+func TestAllFlagsUnconfigured(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	DefaultClient = nil
+	Register("declared")
+
+	require.Equal(t, map[string]bool{"declared": env.IsLocal()}, AllFlags())
+}
@@ -0,0 +1,65 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type anonymousKey struct{}
+
+// AnonymousMiddleware assigns a stable random ID to each visitor, stored in the
+// cookie, so anonymous visitors can be targeted as users before they log in with
+// WithUser(AnonymousID(ctx)). Visitors keep the same ID for a year after their last
+// visit.
+func AnonymousMiddleware(cookie string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id string
+			if c, err := r.Cookie(cookie); err == nil && validAnonymousID(c.Value) {
+				id = c.Value
+			} else {
+				id = newUUID()
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookie,
+				Value:    id,
+				Path:     "/",
+				Expires:  time.Now().Add(365 * 24 * time.Hour),
+				Secure:   r.TLS != nil,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), anonymousKey{}, id)))
+		})
+	}
+}
+
+// AnonymousID returns the ID of the visitor assigned by AnonymousMiddleware, or an
+// empty string if the context does not have one.
+func AnonymousID(ctx context.Context) string {
+	id, _ := ctx.Value(anonymousKey{}).(string)
+	return id
+}
+
+// validAnonymousID accepts only the IDs generated by the middleware, so visitors
+// cannot send arbitrary values as their user.
+func validAnonymousID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, r := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousMiddleware(t *testing.T) {
+	var id string
+	h := AnonymousMiddleware("visitor")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = AnonymousID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Len(t, id, 36)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "visitor", cookies[0].Name)
+	require.Equal(t, id, cookies[0].Value)
+	first := id
+
+	// Returning visitors keep their ID.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	require.Equal(t, first, id)
+
+	// Unknown values are replaced.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "visitor", Value: "admin"})
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	require.NotEqual(t, "admin", id)
+	require.NotEqual(t, first, id)
+}
@@ -1,9 +1,44 @@
 package features
 
+import (
+	"encoding/json"
+)
+
 type flagReply struct {
 	Code    string       `json:"code"`
 	Enabled bool         `json:"enabled"`
 	Tenants []flagTenant `json:"tenants"`
+
+	// Tenants that have the flag disabled even if it is enabled for everyone else.
+	ExcludedTenants []string `json:"excludedTenants,omitempty"`
+
+	// Users with a specific value, that takes precedence over the value of their tenant.
+	Users []flagTenant `json:"users,omitempty"`
+
+	// Targeting rules over the attributes of the evaluation. The first matching rule
+	// takes precedence over the value of the tenant.
+	Rules []flagRule `json:"rules,omitempty"`
+
+	// Maximum staleness in seconds allowed for critical flags. Zero uses the default of the client.
+	TTL int64 `json:"ttl,omitempty"`
+
+	// Flags that change often. The client polls faster while they are being used.
+	Volatile bool `json:"volatile,omitempty"`
+
+	// Flags archived in the server. They always evaluate to their global value and
+	// the code that checks them can be deleted.
+	Archived bool `json:"archived,omitempty"`
+
+	// Flags disabled in an emergency, for example by an automatic rollback of the
+	// server. They evaluate to false whatever the rest of the configuration is.
+	Killed bool `json:"killed,omitempty"`
+
+	// Terminal value of the flags whose rollout is complete. The client skips their
+	// rules and does not count their evaluations in the stats.
+	Frozen *bool `json:"frozen,omitempty"`
+
+	// Value of the flag for the flags that are not only booleans, like percentages.
+	Value json.RawMessage `json:"value,omitempty"`
 }
 
 type flagTenant struct {
@@ -11,14 +46,45 @@ type flagTenant struct {
 	Enabled bool   `json:"enabled"`
 }
 
-type statsRequest struct {
-	Project string      `json:"project"`
-	Stats   []statEntry `json:"stats"`
+// flagRule matches the evaluations whose attribute has one of the values.
+type flagRule struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+	Enabled   bool     `json:"enabled"`
+}
+
+// StatsRequest is a batch of stats sent to the server by an instance of the client.
+type StatsRequest struct {
+	Project    string      `json:"project"`
+	InstanceID string      `json:"instanceId"`
+	Hostname   string      `json:"hostname,omitempty"`
+	Region     string      `json:"region,omitempty"`
+	Service    string      `json:"service,omitempty"`
+	Version    string      `json:"version,omitempty"`
+	Revision   string      `json:"revision,omitempty"`
+	Stats      []StatEntry `json:"stats"`
+
+	// Labels of the infrastructure of the instance configured with WithScope.
+	Scope map[string]string `json:"scope,omitempty"`
 }
 
-type statEntry struct {
+// StatEntry counts the evaluations of a flag during a minute. The bucket is the
+// start of the minute in unix milliseconds.
+type StatEntry struct {
 	Bucket      int64  `json:"bucket"`
 	Flag        string `json:"flag"`
 	EnabledHits int64  `json:"enabledHits"`
 	TotalHits   int64  `json:"totalHits"`
+
+	// User of the evaluations counted in the entry, if they were evaluated with
+	// WithUser. Each user of the flag has its own entry in the minute.
+	User string `json:"user,omitempty"`
+
+	// Archived is true if the flag was archived in the server, so it knows the code
+	// that still evaluates it.
+	Archived bool `json:"archived,omitempty"`
+
+	// Health signals reported by the application with ReportHealth during the minute.
+	HealthyReports   int64 `json:"healthyReports,omitempty"`
+	UnhealthyReports int64 `json:"unhealthyReports,omitempty"`
 }
@@ -0,0 +1,28 @@
+package features
+
+import (
+	"runtime/debug"
+)
+
+// binaryVersion returns the version of the main module and the VCS revision of the
+// binary, to correlate the stats with the deployed versions.
+func binaryVersion() (version, revision string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+	return parseBuildInfo(info)
+}
+
+func parseBuildInfo(info *debug.BuildInfo) (version, revision string) {
+	// Binaries built from a local checkout without a tag do not have a version.
+	if info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			revision = setting.Value
+		}
+	}
+	return version, revision
+}
@@ -0,0 +1,26 @@
+package features
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildInfo(t *testing.T) {
+	version, revision := parseBuildInfo(&debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/foo", Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "0123456789abcdef"},
+		},
+	})
+	require.Equal(t, "v1.2.3", version)
+	require.Equal(t, "0123456789abcdef", revision)
+
+	version, revision = parseBuildInfo(&debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/foo", Version: "(devel)"},
+	})
+	require.Empty(t, version)
+	require.Empty(t, revision)
+}
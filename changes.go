@@ -0,0 +1,173 @@
+package features
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+// Diff describes the changes of the flags between two fetches from the server.
+type Diff struct {
+	// Added flags that were not present before.
+	Added []string
+
+	// Removed flags that are not present anymore.
+	Removed []string
+
+	// Changed flags present in both fetches with a different configuration.
+	Changed []FlagChange
+}
+
+// Empty returns true if there are no changes.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d Diff) codes() []string {
+	codes := slices.Concat(d.Added, d.Removed)
+	for _, change := range d.Changed {
+		codes = append(codes, change.Flag)
+	}
+	return codes
+}
+
+// FlagChange describes the change of a single flag.
+type FlagChange struct {
+	Flag string
+
+	// Global enabled state of the flag before and after the change. It may not
+	// change if only the value or the tenants of the flag changed.
+	Before, After bool
+
+	// Tenants whose value changed. Tenants not configured have a false value.
+	Tenants []TenantChange
+}
+
+// TenantChange describes the change of a tenant of a flag.
+type TenantChange struct {
+	Tenant        string
+	Before, After bool
+}
+
+type changeListeners struct {
+	mu        sync.Mutex
+	next      int
+	listeners map[int]func(Diff)
+}
+
+// OnChange registers fn to be called after each fetch that changes the flags. It is
+// called from the background goroutine of the client, so it should return quickly.
+// The returned function unregisters the listener.
+func (c *Client) OnChange(fn func(Diff)) (stop func()) {
+	c.changes.mu.Lock()
+	defer c.changes.mu.Unlock()
+
+	if c.changes.listeners == nil {
+		c.changes.listeners = make(map[int]func(Diff))
+	}
+	id := c.changes.next
+	c.changes.next++
+	c.changes.listeners[id] = fn
+
+	return func() {
+		c.changes.mu.Lock()
+		defer c.changes.mu.Unlock()
+		delete(c.changes.listeners, id)
+	}
+}
+
+func (c *Client) notifyChanges(diff Diff) {
+	c.changes.mu.Lock()
+	ids := make([]int, 0, len(c.changes.listeners))
+	for id := range c.changes.listeners {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	listeners := make([]func(Diff), 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, c.changes.listeners[id])
+	}
+	c.changes.mu.Unlock()
+
+	// Call outside the lock so the listeners can unregister themselves.
+	for _, fn := range listeners {
+		fn(diff)
+	}
+}
+
+// diffFlags compares two payloads of the server.
+func diffFlags(before, after []flagReply) Diff {
+	prev := make(map[string]flagReply, len(before))
+	for _, f := range before {
+		prev[f.Code] = f
+	}
+
+	var diff Diff
+	for _, f := range after {
+		p, ok := prev[f.Code]
+		delete(prev, f.Code)
+		if !ok {
+			diff.Added = append(diff.Added, f.Code)
+			continue
+		}
+
+		tenants := diffTenants(p, f)
+		if p.Enabled != f.Enabled || len(tenants) > 0 || !slices.Equal(p.Users, f.Users) || !slices.EqualFunc(p.Rules, f.Rules, equalRule) || !bytes.Equal(p.Value, f.Value) || p.Archived != f.Archived || p.Killed != f.Killed || !equalFrozen(p.Frozen, f.Frozen) {
+			diff.Changed = append(diff.Changed, FlagChange{
+				Flag:    f.Code,
+				Before:  p.Enabled,
+				After:   f.Enabled,
+				Tenants: tenants,
+			})
+		}
+	}
+	for code := range prev {
+		diff.Removed = append(diff.Removed, code)
+	}
+	slices.Sort(diff.Removed)
+
+	return diff
+}
+
+func diffTenants(before, after flagReply) []TenantChange {
+	prev := make(map[string]bool, len(before.Tenants))
+	for _, t := range before.Tenants {
+		prev[t.Code] = t.Enabled
+	}
+
+	var changes []TenantChange
+	for _, t := range after.Tenants {
+		p, ok := prev[t.Code]
+		delete(prev, t.Code)
+		if !ok || p != t.Enabled {
+			changes = append(changes, TenantChange{Tenant: t.Code, Before: p, After: t.Enabled})
+		}
+	}
+	for _, t := range before.Tenants {
+		if p, ok := prev[t.Code]; ok {
+			changes = append(changes, TenantChange{Tenant: t.Code, Before: p})
+		}
+	}
+
+	// Excluded tenants are always disabled. Report their effective value with the
+	// global state of the flag.
+	for _, t := range after.ExcludedTenants {
+		if !slices.Contains(before.ExcludedTenants, t) {
+			changes = append(changes, TenantChange{Tenant: t, Before: before.Enabled})
+		}
+	}
+	for _, t := range before.ExcludedTenants {
+		if !slices.Contains(after.ExcludedTenants, t) {
+			changes = append(changes, TenantChange{Tenant: t, After: after.Enabled})
+		}
+	}
+
+	return changes
+}
+
+func equalFrozen(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
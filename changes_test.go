@@ -0,0 +1,124 @@
+package features
+
+import (
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffFlags(t *testing.T) {
+	before := []flagReply{
+		{Code: "same", Enabled: true},
+		{Code: "toggled", Enabled: true},
+		{Code: "tenants", Enabled: true, Tenants: []flagTenant{
+			{Code: "foo", Enabled: true},
+			{Code: "bar", Enabled: true},
+			{Code: "removed", Enabled: true},
+		}},
+		{Code: "removed", Enabled: true},
+	}
+	after := []flagReply{
+		{Code: "same", Enabled: true},
+		{Code: "toggled", Enabled: false},
+		{Code: "tenants", Enabled: true, Tenants: []flagTenant{
+			{Code: "foo", Enabled: false},
+			{Code: "bar", Enabled: true},
+			{Code: "added", Enabled: true},
+		}},
+		{Code: "added", Enabled: true},
+	}
+
+	require.Equal(t, Diff{
+		Added:   []string{"added"},
+		Removed: []string{"removed"},
+		Changed: []FlagChange{
+			{Flag: "toggled", Before: true, After: false},
+			{
+				Flag:   "tenants",
+				Before: true,
+				After:  true,
+				Tenants: []TenantChange{
+					{Tenant: "foo", Before: true, After: false},
+					{Tenant: "added", Before: false, After: true},
+					{Tenant: "removed", Before: true, After: false},
+				},
+			},
+		},
+	}, diffFlags(before, after))
+}
+
+func TestDiffFlagsEmpty(t *testing.T) {
+	flags := []flagReply{
+		{Code: "global", Enabled: true},
+		{Code: "tenants", Enabled: true, Tenants: []flagTenant{{Code: "foo", Enabled: true}}},
+	}
+	require.True(t, diffFlags(flags, flags).Empty())
+}
+
+func TestDiffFlagsRules(t *testing.T) {
+	before := []flagReply{
+		{Code: "same", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: true}}},
+		{Code: "values", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: true}}},
+	}
+	after := []flagReply{
+		{Code: "same", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: true}}},
+		{Code: "values", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: []string{"ES", "PT"}, Enabled: true}}},
+	}
+	require.Equal(t, []string{"values"}, diffFlags(before, after).codes())
+}
+
+func TestDiffFlagsFrozen(t *testing.T) {
+	yes, alsoYes, no := true, true, false
+	before := []flagReply{
+		{Code: "frozen", Enabled: true, Frozen: &yes},
+		{Code: "refrozen", Enabled: true, Frozen: &yes},
+		{Code: "unfrozen", Enabled: true, Frozen: &yes},
+	}
+	after := []flagReply{
+		{Code: "frozen", Enabled: true, Frozen: &alsoYes},
+		{Code: "refrozen", Enabled: true, Frozen: &no},
+		{Code: "unfrozen", Enabled: true},
+	}
+
+	diff := diffFlags(before, after)
+	require.Equal(t, []string{"refrozen", "unfrozen"}, diff.codes())
+}
+
+func TestOnChange(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		var diffs []Diff
+		stop := DefaultClient.OnChange(func(diff Diff) {
+			diffs = append(diffs, diff)
+		})
+
+		sources := fakeSources{
+			"example.com": {{Code: "foo", Enabled: true}},
+		}
+		DefaultClient.client = &http.Client{Transport: sources}
+		DefaultClient.fetch()
+		require.Empty(t, diffs)
+
+		// Same flags do not notify.
+		time.Sleep(DefaultClient.maxFetchInterval)
+		DefaultClient.fetch()
+		require.Empty(t, diffs)
+
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: false}}
+		DefaultClient.fetch()
+		require.Equal(t, []Diff{{Changed: []FlagChange{{Flag: "foo", Before: true}}}}, diffs)
+
+		stop()
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}}
+		DefaultClient.fetch()
+		require.Len(t, diffs, 1)
+	})
+}
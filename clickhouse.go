@@ -0,0 +1,79 @@
+package features
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type clickHouseSink struct {
+	insertURL string
+	client    *http.Client
+}
+
+// NewClickHouseSink sends the evaluation events to a ClickHouse table through its HTTP
+// interface with asynchronous inserts. Credentials can be included in the URL. The
+// table should have the columns:
+//
+//	time DateTime64(3), project String, flag String, tenant String, user String, enabled Bool, reason LowCardinality(String)
+func NewClickHouseSink(serverURL, table string) Sink {
+	qs := make(url.Values)
+	qs.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", table))
+	qs.Set("async_insert", "1")
+	qs.Set("wait_for_async_insert", "0")
+
+	return &clickHouseSink{
+		insertURL: serverURL + "/?" + qs.Encode(),
+		client:    http.DefaultClient,
+	}
+}
+
+type clickHouseRow struct {
+	Time    string `json:"time"`
+	Project string `json:"project"`
+	Flag    string `json:"flag"`
+	Tenant  string `json:"tenant"`
+	User    string `json:"user,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+func (s *clickHouseSink) Send(ctx context.Context, events []Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		row := clickHouseRow{
+			Time:    event.Time.UTC().Format("2006-01-02 15:04:05.000"),
+			Project: event.Project,
+			Flag:    event.Flag,
+			Tenant:  event.Tenant,
+			User:    event.User,
+			Enabled: event.Enabled,
+			Reason:  string(event.Reason),
+		}
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("cannot encode event: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.insertURL, &buf)
+	if err != nil {
+		return fmt.Errorf("cannot create clickhouse request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send events to clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected clickhouse status code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
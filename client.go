@@ -1,115 +1,302 @@
 package features
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/altipla-consulting/env"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/singleflight"
 )
 
-type featuresClient struct {
+// Client fetches the flags of a project in the background and evaluates them.
+type Client struct {
 	// Initialized configurations.
-	evalURL  string
-	statsURL string
-	sf       singleflight.Group
-	local    bool
-	client   *http.Client
-	logger   *slog.Logger
-	project  string
+	evalURL       string
+	overrideURLs  []string
+	statsURL      string
+	sf            singleflight.Group
+	local         bool
+	static        bool
+	noFetch       bool // stats-only clients that evaluate the overrides and defaults
+	failOpen      bool
+	apiKey        string
+	statsAPIKey   string
+	idToken       *idTokenSource
+	client        *http.Client
+	hedgeDelay    time.Duration
+	fetchBudget   time.Duration
+	logger        Logger
+	reporter      ErrorReporter
+	project       string
+	defaultTenant string
 
 	// Background control.
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closingMu sync.RWMutex
+	closing   bool
+
+	// Background goroutines running and the fetches in progress.
+	goroutines      atomic.Int32
+	goroutineLabels bool
+	closeMu         sync.Mutex
+	closeErrs       []error
+
+	// Overrides of the flags on top of the server payload.
+	overridesMu sync.RWMutex
+	overrides   map[Layer]map[string]bool
+
+	// Closed after the first successful fetch.
+	ready     chan struct{}
+	readyOnce sync.Once
 
 	// Cached flags.
-	mu          sync.RWMutex // protects stale, flags and lastRefresh
+	mu          sync.RWMutex // protects stale, flags, volatile, payload, lastRefresh and lastFailure
 	stale       time.Time
 	flags       []flagReply
+	volatile    map[string]bool // replaced, never modified, in each fetch
 	lastRefresh time.Time
+	lastFailure time.Time
+	flagTTLs    map[string]time.Duration
+	payload     lastPayload
 
 	// Background fetching.
 	ticker          *time.Ticker
-	lastAccess      time.Time
-	refreshInterval time.Duration
-	accessCh        chan struct{}
+	lastAccess      atomic.Int64       // unix nanoseconds of the last evaluation
+	lastVolatile    atomic.Int64       // unix nanoseconds of the last evaluation of a volatile flag
+	refreshed       chan time.Duration // stale duration of each successful fetch
+	refreshInterval time.Duration      // starts fast and slows down in the first tick if there are no accesses
+
+	// Listeners of the changes and evaluations of the flags.
+	changes     changeListeners
+	evaluations evaluationListeners
+
+	// Flap detection. Only accessed from fetch, that never runs concurrently.
+	flaps     *flapOptions
+	flapTimes map[string][]time.Time
 
 	// Mostly constants except for testing.
 	staleDuration      time.Duration
 	staleDurationError time.Duration
+	maxStaleness       time.Duration
 	maxFetchInterval   time.Duration
-
-	statsCh chan accessEvent
-	stats   map[string]*flagStats
+	criticalStaleness  time.Duration
+
+	instanceID      string
+	hostname        string
+	region          string
+	service         string
+	version         string // version and VCS revision of the binary
+	revision        string
+	scope           map[string]string
+	serverClock     bool
+	clockSkew       atomic.Int64 // nanoseconds between the server and the local clock
+	statsCh         chan accessEvent
+	stats           map[string]*flagStats
+	rates           *accessRates
+	statsPending    []statsBatch
+	statsEntries    int
+	maxStatsEntries int
+	maxStatsChunk   int
+	statsRetention  time.Duration
+	statsEnabled    func() bool
+	statsMirror     *os.File
+	encoder         StatsEncoder
+	encoderRejected atomic.Bool
+	statsHandoff    chan []statsBatch // nil if the stats are disabled
+	skipFinalStats  atomic.Bool
+	sink            Sink
+	sinkCh          chan Event
+	metrics         *metrics
+	trace           bool
+	faults          atomic.Pointer[FaultInjection] // nil without fault injection
+	duplicatePolicy DuplicatePolicy
+	transform       func([]FlagConfig) []FlagConfig
+	emptyTenants    EmptyTenantPolicy
+	rules           *ruleMemo
+	duplicates      atomic.Pointer[map[string]bool] // nil if the payload has no duplicated flags
+	expired         atomic.Bool                     // true while the flags are older than the maximum staleness
 }
 
-func newClient(serverURL, project string, opts *configureOptions) *featuresClient {
-	if opts.logger == nil {
-		opts.logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
-			Level: slog.LevelWarn,
-		}))
-	}
+// archivedWarnings are the archived flags already warned in the process.
+var archivedWarnings sync.Map
 
+// missingTenantWarnings are the tenant-scoped flags already warned in the process
+// because they were evaluated without a tenant.
+var missingTenantWarnings sync.Map
+
+func buildEvalURL(serverURL, project, service string, scope map[string]string) string {
 	qs := make(url.Values)
 	qs.Set("project", project)
+	if service != "" {
+		qs.Set("service", service)
+	}
+	for key, value := range scope {
+		qs.Set("scope."+key, value)
+	}
 	evalURL, err := url.Parse(serverURL)
 	if err != nil {
 		panic(fmt.Sprintf("cannot parse features url: %s", err.Error()))
 	}
 	evalURL.Path += "/eval"
 	evalURL.RawQuery = qs.Encode()
+	return evalURL.String()
+}
+
+// NewClient creates a new client with the provided server URL and project, and starts
+// a background synchronization process. Most applications should use Configure
+// instead to initialize the default client.
+func NewClient(serverURL, project string, opts ...ConfigureOption) *Client {
+	return newClient(serverURL, project, newConfigureOptions(opts))
+}
+
+func newClient(serverURL, project string, opts *configureOptions) *Client {
+	if opts.logger == nil {
+		opts.logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+			Level: slog.LevelWarn,
+		}))
+	}
+
+	serverURL, socket := parseUnixURL(serverURL)
+
+	var overrideURLs []string
+	for _, source := range opts.overrideSources {
+		overrideURLs = append(overrideURLs, buildEvalURL(source.serverURL, source.project, opts.service, opts.scope))
+	}
 
 	statsURL, err := url.Parse(serverURL)
 	if err != nil {
 		panic(fmt.Sprintf("cannot parse features url: %s", err.Error()))
 	}
 	statsURL.Path += "/stats"
+	if opts.statsURL != "" {
+		statsURL, err = url.Parse(opts.statsURL)
+		if err != nil {
+			panic(fmt.Sprintf("cannot parse features stats url: %s", err.Error()))
+		}
+	}
+
+	if opts.noMetadata {
+		opts.hostname = ""
+		opts.region = ""
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	client := &featuresClient{
-		evalURL:            evalURL.String(),
+	client := &Client{
+		evalURL:            buildEvalURL(serverURL, project, opts.service, opts.scope),
+		overrideURLs:       overrideURLs,
 		statsURL:           statsURL.String(),
-		local:              env.IsLocal(),
-		client:             http.DefaultClient,
+		local:              opts.local,
+		noFetch:            opts.disableFetch,
+		failOpen:           opts.failOpen,
+		apiKey:             opts.apiKey,
+		statsAPIKey:        opts.statsAPIKey,
+		idToken:            opts.idToken,
+		flagTTLs:           opts.flagTTLs,
+		client:             newHTTPClient(opts, socket),
+		hedgeDelay:         opts.hedgeDelay,
+		fetchBudget:        opts.fetchBudget,
 		logger:             opts.logger,
+		reporter:           opts.reporter,
+		flaps:              opts.flaps,
+		flapTimes:          make(map[string][]time.Time),
 		project:            project,
+		defaultTenant:      opts.defaultTenant,
 		ctx:                ctx,
 		cancel:             cancel,
-		accessCh:           make(chan struct{}, 100),
-		staleDuration:      1 * time.Minute,
+		ready:              make(chan struct{}),
+		refreshed:          make(chan time.Duration, 1),
+		staleDuration:      cmp.Or(opts.staleDuration, 1*time.Minute),
+		maxStaleness:       opts.maxStaleness,
 		staleDurationError: 5 * time.Minute,
-		refreshInterval:    5 * time.Minute,
+		refreshInterval:    15 * time.Second,
 		maxFetchInterval:   10 * time.Second,
+		criticalStaleness:  cmp.Or(opts.criticalStaleness, max(opts.maxStaleness, 0), 30*time.Minute),
+		instanceID:         newUUID(),
+		hostname:           opts.hostname,
+		region:             opts.region,
+		service:            opts.service,
+		scope:              maps.Clone(opts.scope),
+		serverClock:        opts.serverClock,
+		encoder:            opts.statsEncoder,
+		goroutineLabels:    opts.goroutineLabels,
+		trace:              opts.trace,
+		duplicatePolicy:    opts.duplicatePolicy,
+		transform:          opts.transform,
+		emptyTenants:       opts.emptyTenantPolicy,
 		statsCh:            make(chan accessEvent, 500),
 		stats:              make(map[string]*flagStats),
+		rates:              newAccessRates(),
+		rules:              newRuleMemo(),
+		maxStatsEntries:    10000,
+		maxStatsChunk:      1000,
+		statsRetention:     opts.statsRetention,
+		statsEnabled:       opts.statsEnabled,
+		sink:               opts.sink,
+		sinkCh:             make(chan Event, 10000),
+	}
+
+	client.version, client.revision = binaryVersion()
+	client.loadOverrides(opts.overridesFile)
+	if opts.faults != nil {
+		client.InjectFaults(*opts.faults)
+	}
+	if opts.pushgatewayURL != "" {
+		client.metrics = newMetrics(opts.pushgatewayURL, opts.pushgatewayJob, cmp.Or(client.hostname, client.instanceID))
 	}
+	client.openStatsMirror(opts.statsMirror)
 
-	client.wg.Add(1)
-	go client.backgroundFetch()
+	if opts.disableFetch {
+		client.readyOnce.Do(func() { close(client.ready) })
+	} else {
+		client.goBackground("fetch", client.backgroundFetch)
+	}
 
 	if !opts.disableStats {
-		client.wg.Add(1)
-		go client.backgroundStats()
+		client.statsHandoff = make(chan []statsBatch, 1)
+		client.goBackground("stats", client.backgroundStats)
+	}
+
+	if opts.sink != nil {
+		client.goBackground("sink", client.backgroundSink)
+	}
+
+	if opts.initialFetchTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.initialFetchTimeout)
+		defer cancel()
+		if err := client.WaitForReady(ctx); err != nil {
+			client.logger.Warn("feature flags: initial fetch failed", slog.String("error", err.Error()))
+		}
 	}
 
 	return client
 }
 
-func (c *featuresClient) backgroundFetch() {
-	defer c.wg.Done()
-
+func (c *Client) backgroundFetch() {
 	c.ticker = time.NewTicker(c.refreshInterval)
 	defer c.ticker.Stop()
 
+	// Refresh shortly before the flags get stale if they are being used, so the
+	// evaluations do not have to wait for a fetch.
+	var prefetch <-chan time.Time
+	var staleDuration time.Duration
+
 	for {
 		select {
 		case <-c.ticker.C:
@@ -118,11 +305,15 @@ func (c *featuresClient) backgroundFetch() {
 			c.fetch()
 			c.adjustInterval()
 
-		case <-c.accessCh:
-			c.logger.Debug("feature flags: access registered")
+		case staleDuration = <-c.refreshed:
+			prefetch = time.After(staleDuration * 8 / 10)
 
-			c.lastAccess = time.Now()
-			c.adjustInterval()
+		case <-prefetch:
+			prefetch = nil
+			if time.Since(time.Unix(0, c.lastAccess.Load())) < staleDuration {
+				c.logger.Debug("feature flags: prefetch before stale")
+				c.fetch()
+			}
 
 		case <-c.ctx.Done():
 			return
@@ -130,12 +321,21 @@ func (c *featuresClient) backgroundFetch() {
 	}
 }
 
-func (c *featuresClient) adjustInterval() {
+func (c *Client) adjustInterval() {
 	old := c.refreshInterval
 
-	switch sinceAccess := time.Since(c.lastAccess); {
+	// If the server marks the flags that change often, only the access to them
+	// refreshes faster. The rest of the flags rarely change.
+	c.mu.RLock()
+	fast := len(c.volatile) == 0
+	c.mu.RUnlock()
+	if !fast {
+		fast = time.Since(time.Unix(0, c.lastVolatile.Load())) < 5*time.Minute
+	}
+
+	switch sinceAccess := time.Since(time.Unix(0, c.lastAccess.Load())); {
 	// First 5 minutes after access, refresh every 15 seconds.
-	case sinceAccess < 5*time.Minute:
+	case sinceAccess < 5*time.Minute && fast:
 		c.refreshInterval = 15 * time.Second
 
 	// Next 30 minutes after access, refresh every minute.
@@ -153,37 +353,97 @@ func (c *featuresClient) adjustInterval() {
 	}
 }
 
-func (c *featuresClient) Close() {
-	c.cancel()
-	c.wg.Wait()
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.closingMu.Lock()
+		c.closing = true
+		c.closingMu.Unlock()
+
+		c.cancel()
+		c.wg.Wait()
+
+		if c.statsMirror != nil {
+			if err := c.statsMirror.Close(); err != nil {
+				c.closeError(fmt.Errorf("cannot close stats mirror: %w", err))
+			}
+		}
+		c.closeMetrics()
+	})
+
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	return errors.Join(c.closeErrs...)
+}
+
+// closeError records an error of the shutdown, like stats or events that could not
+// be sent, to return it from Close.
+func (c *Client) closeError(err error) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	c.closeErrs = append(c.closeErrs, err)
 }
 
-func (c *featuresClient) isStale() bool {
+func (c *Client) isStale() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.stale.IsZero() || time.Since(c.stale) >= 0
 }
 
-func (c *featuresClient) fetch() {
-	_, _, _ = c.sf.Do("fetch", func() (interface{}, error) {
-		c.wg.Add(1)
-		defer c.wg.Done()
+func (c *Client) fetch() {
+	if c.static || c.noFetch {
+		return
+	}
 
-		c.logger.Debug("feature flags: fetch", slog.Time("stale", c.stale))
+	_, _, _ = c.sf.Do("fetch", c.doFetch)
+}
 
-		if err := c.safeFetch(); err != nil {
-			slog.Warn("feature flags: fetch failed", slog.String("error", err.Error()))
+// fetchWithBudget fetches the flags from the request path. With a fetch budget it
+// waits up to the budget and lets the fetch continue in the background.
+func (c *Client) fetchWithBudget() {
+	if c.static {
+		return
+	}
+	if c.fetchBudget <= 0 {
+		c.fetch()
+		return
+	}
 
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			c.stale = time.Now().Add(c.staleDurationError)
-		}
+	timer := time.NewTimer(c.fetchBudget)
+	defer timer.Stop()
+	select {
+	case <-c.sf.DoChan("fetch", c.doFetch):
+	case <-timer.C:
+		c.logger.Debug("feature flags: fetch budget exceeded")
+	}
+}
 
+// doFetch runs a single fetch. It should always be called through the singleflight group.
+func (c *Client) doFetch() (interface{}, error) {
+	// Evaluations can fetch concurrently with Close.
+	if !c.addBackground() {
 		return nil, nil
-	})
+	}
+	defer c.doneBackground()
+
+	c.logger.Debug("feature flags: fetch", slog.Time("stale", c.stale))
+
+	if err := c.safeFetch(); err != nil {
+		if c.metrics != nil {
+			c.metrics.fetchErrors.Add(1)
+		}
+		c.logger.Warn("feature flags: fetch failed", slog.String("error", err.Error()))
+		c.reportError("feature flags: fetch failed: %w", err)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.stale = time.Now().Add(c.staleDurationError)
+		c.lastFailure = time.Now()
+	}
+
+	return nil, nil
 }
 
-func (c *featuresClient) safeFetch() error {
+func (c *Client) safeFetch() error {
 	c.mu.RLock()
 	lastFetch := c.lastRefresh
 	c.mu.RUnlock()
@@ -192,81 +452,559 @@ func (c *featuresClient) safeFetch() error {
 		return nil
 	}
 
+	if c.metrics != nil {
+		c.metrics.fetches.Add(1)
+	}
+
 	ctx, cancel := context.WithTimeout(c.ctx, 3*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.evalURL, nil)
+	// Fetch all the sources at the same time. If any of them fails we keep the previous
+	// flags instead of serving a view without the overrides.
+	sources := append([]string{c.evalURL}, c.overrideURLs...)
+	results := make([][]flagReply, len(sources))
+	duplicates := make([][]string, len(sources))
+	var main sourcePayload
+	g, ctx := errgroup.WithContext(ctx)
+	for i, source := range sources {
+		g.Go(func() error {
+			payload, err := c.fetchSource(ctx, source)
+			if err != nil {
+				return err
+			}
+			results[i] = payload.flags
+			duplicates[i] = payload.duplicates
+			if i == 0 {
+				main = payload
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	fetched := mergeSources(results)
+	if c.transform != nil {
+		transformed, err := c.transformPayload(fetched)
+		if err != nil {
+			return err
+		}
+		fetched = transformed
+	}
+	c.storeDuplicates(slices.Concat(duplicates...))
+	if c.serverClock {
+		c.clockSkew.Store(int64(main.skew))
+	}
+
+	// Keep the exact body of the server, unless we had to merge multiple sources or
+	// transform the flags.
+	raw, etag := main.raw, main.etag
+	if len(sources) > 1 || c.transform != nil {
+		raw, _ = json.Marshal(fetched)
+		etag = ""
+	}
+	if etag == "" {
+		etag = payloadETag(raw)
+	}
+
+	c.mu.Lock()
+	previous := c.flags
+	c.flags = fetched
+	c.rules.reset()
+	c.volatile = volatileFlags(fetched)
+	c.payload = lastPayload{raw: raw, etag: etag, fetchedAt: time.Now()}
+	c.stale = time.Now().Add(c.staleDuration)
+	c.lastRefresh = time.Now()
+	c.mu.Unlock()
+
+	c.warnKilled(previous, fetched)
+	if c.expired.Swap(false) {
+		c.logger.Info("feature flags: flags refreshed after exceeding the maximum staleness")
+	}
+
+	// Compare with the previous flags only after the first fetch. The initial load is
+	// not a change of the flags.
+	if c.Ready() {
+		if diff := diffFlags(previous, fetched); !diff.Empty() {
+			c.detectFlaps(previous, fetched, diff.codes())
+			c.notifyChanges(diff)
+		}
+	}
+
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+
+	select {
+	case c.refreshed <- c.staleDuration:
+	default:
+	}
+
+	return nil
+}
+
+// sourcePayload is the response of a single source.
+type sourcePayload struct {
+	flags []flagReply
+	raw   []byte
+	etag  string
+
+	// Codes of the flags that were duplicated in the payload.
+	duplicates []string
+
+	// Difference between the Date header of the server and the local clock.
+	skew time.Duration
+}
+
+func (c *Client) fetchSource(ctx context.Context, evalURL string) (sourcePayload, error) {
+	if codes := registeredFlags(); len(codes) > 0 {
+		evalURL += "&" + url.Values{"flag": codes}.Encode()
+	}
+	// Faults are injected before sharing the fetch to not fail other clients.
+	if err := c.injectFault(ctx, faultFetch); err != nil {
+		return sourcePayload{}, fmt.Errorf("cannot fetch: %w", err)
+	}
+	return sharedFetch(c, evalURL, c.maxFetchInterval, func() (sourcePayload, error) {
+		return c.requestSource(ctx, evalURL)
+	})
+}
+
+func (c *Client) requestSource(ctx context.Context, evalURL string) (sourcePayload, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, evalURL, nil)
 	if err != nil {
-		return fmt.Errorf("cannot create fetch request: %w", err)
+		return sourcePayload{}, fmt.Errorf("cannot create fetch request: %w", err)
+	}
+	if err := c.authorize(req); err != nil {
+		return sourcePayload{}, err
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.hedgedDo(req)
 	if err != nil {
-		return fmt.Errorf("cannot fetch: %w", err)
+		return sourcePayload{}, fmt.Errorf("cannot fetch: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected fetch status code %d", resp.StatusCode)
+		return sourcePayload{}, fmt.Errorf("unexpected fetch status code %d", resp.StatusCode)
 	}
 
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return sourcePayload{}, fmt.Errorf("cannot read response: %w", err)
+	}
 	var fetched []flagReply
-	if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
-		return fmt.Errorf("cannot decode response: %w", err)
+	if err := json.Unmarshal(raw, &fetched); err != nil {
+		return sourcePayload{}, fmt.Errorf("cannot decode response: %w", err)
+	}
+	var duplicates []string
+	if c.duplicatePolicy == DuplicateLastWins {
+		fetched, duplicates = dedupeFlags(fetched)
 	}
+	if err := validatePayload(fetched); err != nil {
+		return sourcePayload{}, fmt.Errorf("invalid payload, keeping the previous flags: %w", err)
+	}
+	payload := sourcePayload{
+		flags:      fetched,
+		raw:        raw,
+		etag:       resp.Header.Get("ETag"),
+		duplicates: duplicates,
+	}
+	if len(duplicates) > 0 {
+		// Forward the resolved flags instead of the ambiguous body of the server.
+		payload.raw, _ = json.Marshal(fetched)
+		payload.etag = ""
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		payload.skew = time.Until(date)
+	}
+	return payload, nil
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.flags = fetched
-	c.stale = time.Now().Add(c.staleDuration)
-	c.lastRefresh = time.Now()
+func volatileFlags(flags []flagReply) map[string]bool {
+	var volatile map[string]bool
+	for _, f := range flags {
+		if f.Volatile {
+			if volatile == nil {
+				volatile = make(map[string]bool)
+			}
+			volatile[f.Code] = true
+		}
+	}
+	return volatile
+}
 
-	return nil
+// accessVolatile records the access to the flag if it is volatile, so the
+// background fetch polls faster.
+func (c *Client) accessVolatile(volatile map[string]bool, flag string) {
+	if volatile[flag] {
+		c.lastVolatile.Store(time.Now().UnixNano())
+	}
+}
+
+// mergeSources combines the flags of multiple sources. Flags of later sources replace
+// entirely the flags with the same code of the previous ones.
+func mergeSources(sources [][]flagReply) []flagReply {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+
+	var merged []flagReply
+	index := make(map[string]int)
+	for _, flags := range sources {
+		for _, f := range flags {
+			if i, ok := index[f.Code]; ok {
+				merged[i] = f
+				continue
+			}
+			index[f.Code] = len(merged)
+			merged = append(merged, f)
+		}
+	}
+	return merged
 }
 
-func (c *featuresClient) IsEnabled(flag, tenant string) bool {
+func (c *Client) IsEnabled(flag, tenant string) bool {
+	return c.Detail(flag, tenant).Enabled
+}
+
+// Detail evaluates the flag for the tenant and returns the result with the reason
+// that explains it.
+func (c *Client) Detail(flag, tenant string) FlagDetail {
+	return c.detail(flag, tenant, "", nil)
+}
+
+// DetailUser evaluates the flag for the user of the tenant and returns the result
+// with the reason that explains it.
+func (c *Client) DetailUser(flag, tenant, user string) FlagDetail {
+	return c.detail(flag, tenant, user, nil)
+}
+
+// DetailAttributes evaluates the flag for the user of the tenant with the attributes
+// used by the targeting rules, like the country or the plan, and returns the result
+// with the reason that explains it.
+func (c *Client) DetailAttributes(flag, tenant, user string, attributes map[string]string) FlagDetail {
+	return c.detail(flag, tenant, user, attributes)
+}
+
+func (c *Client) detail(flag, tenant, user string, attributes map[string]string) FlagDetail {
+	detail, _ := c.detailFlags(flag, tenant, user, attributes)
+	return detail
+}
+
+// detailFlags evaluates the flag like detail and returns the flags used in the
+// evaluation. They are nil for the overrides and the local evaluations, that do not
+// read the flags.
+func (c *Client) detailFlags(flag, tenant, user string, attributes map[string]string) (FlagDetail, []flagReply) {
+	if detail, ok := c.override(flag, tenant); ok {
+		c.trackAccess(flag, user, detail.Enabled)
+		c.emitEvent(flag, tenant, user, detail)
+		return detail, nil
+	}
+
 	if c.local {
+		detail := FlagDetail{Enabled: true, Reason: ReasonLocal}
+		c.emitEvent(flag, tenant, user, detail)
+		return detail, nil
+	}
+
+	revalidating := c.access()
+
+	// Critical flags refresh the cache sooner if it is older than their TTL.
+	if c.expiredTTL(flag) {
+		c.fetchWithBudget()
+	}
+
+	c.mu.RLock()
+	flags, fresh := c.usableFlags()
+	c.accessVolatile(c.volatile, flag)
+	c.mu.RUnlock()
+
+	detail := c.evaluate(flags, flag, tenant, user, attributes)
+
+	// Frozen flags are not counted in the stats, the server already knows their value.
+	frozen := detail.Reason == ReasonFrozen
+	if !fresh {
+		detail.Reason = ReasonStale
+	} else if revalidating && !frozen && detail.Reason != ReasonNotFound {
+		detail.Reason = ReasonStaleRevalidating
+	}
+
+	if !frozen {
+		c.trackAccess(flag, user, detail.Enabled)
+	}
+	c.emitEvent(flag, tenant, user, detail)
+	return detail, flags
+}
+
+// expiredTTL returns true if the flag has a shorter TTL than the rest and the cached
+// flags are older than it. After a failed fetch it waits like any other stale access
+// to avoid hammering the server.
+func (c *Client) expiredTTL(flag string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ttl := c.flagTTLs[flag]
+	if ttl == 0 {
+		for _, f := range c.flags {
+			if f.Code == flag {
+				ttl = time.Duration(f.TTL) * time.Second
+				break
+			}
+		}
+	}
+	if ttl == 0 {
+		return false
+	}
+
+	return time.Since(c.lastRefresh) >= ttl && time.Since(c.lastFailure) >= c.staleDurationError
+}
+
+// evaluate the flag applying the fallback value if it is unknown.
+func (c *Client) evaluate(flags []flagReply, flag, tenant, user string, attributes map[string]string) FlagDetail {
+	var detail FlagDetail
+	if c.trace {
+		var trace []TraceStep
+		detail = traceEvaluate(flags, flag, tenant, user, attributes, c.rules, &trace)
+		detail.Trace = trace
+	} else {
+		detail = traceEvaluate(flags, flag, tenant, user, attributes, c.rules, nil)
+	}
+	if detail.Reason == ReasonNotFound {
+		detail.Enabled = c.failOpen
+		if def, ok := definedDefault(flag); ok {
+			detail.Enabled = def
+		}
+		return detail
+	}
+	if detail.Reason == ReasonArchived {
+		c.warnArchived(flag)
+	}
+	if detail.Reason == ReasonTenantMissing {
+		detail.Enabled = c.emptyTenant(flag)
+	}
+	if duplicates := c.duplicates.Load(); duplicates != nil && (*duplicates)[flag] {
+		detail.Duplicated = true
+	}
+	detail.Layer = LayerServer
+	return detail
+}
+
+// storeDuplicates records the flags resolved from duplicated entries of the payload.
+func (c *Client) storeDuplicates(codes []string) {
+	if len(codes) == 0 {
+		c.duplicates.Store(nil)
+		return
+	}
+
+	if c.metrics != nil {
+		c.metrics.duplicateFlags.Add(int64(len(codes)))
+	}
+	duplicates := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		duplicates[code] = true
+	}
+
+	// Log only when the duplicated flags change, not in every fetch.
+	if previous := c.duplicates.Swap(&duplicates); previous == nil || !maps.Equal(*previous, duplicates) {
+		c.logger.Warn("feature flags: payload with duplicated flags, using the last entry of each one", slog.Any("flags", codes))
+	}
+}
+
+// warnArchived logs a warning the first time the process evaluates each archived flag.
+func (c *Client) warnArchived(flag string) {
+	if _, loaded := archivedWarnings.LoadOrStore(flag, struct{}{}); loaded {
+		return
+	}
+	c.logger.Warn("feature flags: evaluating an archived flag, the code that checks it can be deleted", slog.String("flag", flag))
+}
+
+// emptyTenant returns the value of a tenant-scoped flag evaluated without a tenant,
+// that is enabled because the flag itself is not disabled.
+func (c *Client) emptyTenant(flag string) bool {
+	switch c.emptyTenants {
+	case EmptyTenantGlobal:
 		return true
+	case EmptyTenantWarn:
+		if _, loaded := missingTenantWarnings.LoadOrStore(flag, struct{}{}); !loaded {
+			c.logger.Warn("feature flags: tenant-scoped flag evaluated without a tenant", slog.String("flag", flag))
+		}
+	}
+	return false
+}
+
+// usableFlags returns the cached flags, or false if they are older than the maximum
+// staleness and should not be used. It should be called with the lock held.
+func (c *Client) usableFlags() ([]flagReply, bool) {
+	if c.injectedStale() {
+		return c.flags, false
+	}
+	if c.maxStaleness > 0 && !c.lastRefresh.IsZero() && time.Since(c.lastRefresh) >= c.maxStaleness {
+		if !c.expired.Swap(true) {
+			c.logger.Error("feature flags: flags older than the maximum staleness, using the defaults", slog.Time("lastRefresh", c.lastRefresh))
+		}
+		if c.metrics != nil {
+			c.metrics.staleEvaluations.Add(1)
+		}
+		return nil, false
 	}
+	return c.flags, true
+}
 
-	if c.isStale() {
-		c.fetch()
+// access registers a new access to the flags fetching them first if they are stale.
+// It returns true if the stale flags are used while they are refreshed in the
+// background.
+func (c *Client) access() bool {
+	var revalidating bool
+	if !c.noFetch && c.isStale() {
+		if c.serveStale() {
+			// Use the stale flags while they are refreshed in the background.
+			c.sf.DoChan("fetch", c.doFetch)
+			revalidating = true
+		} else {
+			c.fetchWithBudget()
+		}
+	}
+	c.lastAccess.Store(time.Now().UnixNano())
+	return revalidating
+}
+
+// serveStale returns true if the stale flags can still be used without waiting for
+// a fetch, because they are newer than the maximum staleness.
+func (c *Client) serveStale() bool {
+	if c.maxStaleness <= 0 {
+		return false
 	}
-	c.accessCh <- struct{}{}
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return !c.lastRefresh.IsZero() && time.Since(c.lastRefresh) < c.maxStaleness
+}
 
-	for _, f := range c.flags {
+// traceEvaluate evaluates the flag recording the rules checked in the trace, if
+// it is not nil. The targeting rules are memoized in rules, if it is not nil.
+func traceEvaluate(flags []flagReply, flag, tenant, user string, attributes map[string]string, rules *ruleMemo, trace *[]TraceStep) FlagDetail {
+	for i := range flags {
+		f := &flags[i]
 		if f.Code != flag {
 			continue
 		}
 
+		// Killed flags are disabled before anything else.
+		if f.Killed {
+			addStep(trace, ReasonKilled, true, "")
+			return FlagDetail{Reason: ReasonKilled}
+		}
+
+		// Frozen flags skip the rules to evaluate as fast as possible.
+		if f.Frozen != nil {
+			addStep(trace, ReasonFrozen, true, "")
+			return FlagDetail{Enabled: *f.Frozen, Reason: ReasonFrozen}
+		}
+
+		// Archived flags ignore the rest of the configuration.
+		if f.Archived {
+			addStep(trace, ReasonArchived, true, "")
+			return FlagDetail{Enabled: f.Enabled, Reason: ReasonArchived}
+		}
+
+		// Excluded tenants are disabled whatever the rest of the configuration is.
+		if slices.Contains(f.ExcludedTenants, tenant) {
+			addStep(trace, ReasonTenantExcluded, true, "")
+			return FlagDetail{Reason: ReasonTenantExcluded}
+		}
+		if len(f.ExcludedTenants) > 0 {
+			addStep(trace, ReasonTenantExcluded, false, "tenant not excluded")
+		}
+
+		// Users have precedence over their tenant, unless the flag is disabled.
+		if user != "" && len(f.Users) > 0 {
+			if !f.Enabled {
+				addStep(trace, ReasonUser, false, "flag disabled")
+			} else {
+				for _, u := range f.Users {
+					if u.Code == user {
+						addStep(trace, ReasonUser, true, "")
+						return FlagDetail{Enabled: u.Enabled, Reason: ReasonUser}
+					}
+				}
+				addStep(trace, ReasonUser, false, "user not configured")
+			}
+		}
+
+		// Targeting rules have precedence over the tenant, unless the flag is disabled.
+		if len(attributes) > 0 && len(f.Rules) > 0 {
+			if !f.Enabled {
+				addStep(trace, ReasonAttribute, false, "flag disabled")
+			} else if rule, ok := rules.match(f, attributes); ok {
+				addStep(trace, ReasonAttribute, true, rule.Attribute)
+				return FlagDetail{Enabled: rule.Enabled, Reason: ReasonAttribute}
+			} else {
+				addStep(trace, ReasonAttribute, false, "no rule matched")
+			}
+		}
+
 		// Global flags always depend on the enabled state of the flag.
 		if len(f.Tenants) == 0 {
-			c.trackAccess(flag, f.Enabled)
-			return f.Enabled
+			addStep(trace, ReasonGlobal, true, "")
+			return FlagDetail{Enabled: f.Enabled, Reason: ReasonGlobal}
 		}
 
 		// Disabled flags always return false for each tenant too.
 		if !f.Enabled {
-			c.trackAccess(flag, false)
-			return false
+			addStep(trace, ReasonDisabled, true, "")
+			return FlagDetail{Reason: ReasonDisabled}
+		}
+
+		// Tenant-scoped flags evaluated without a tenant are usually a bug of the caller.
+		if tenant == "" {
+			addStep(trace, ReasonTenant, false, "empty tenant")
+			return FlagDetail{Reason: ReasonTenantMissing}
 		}
 
-		// Search for the specific tenant in the list. If we requested an empty one it won't match anyway
-		// and return false.
+		// Search for the specific tenant in the list.
 		for _, t := range f.Tenants {
 			if t.Code == tenant {
-				c.trackAccess(flag, t.Enabled)
-				return t.Enabled
+				addStep(trace, ReasonTenant, true, "")
+				return FlagDetail{Enabled: t.Enabled, Reason: ReasonTenant}
 			}
 		}
 
-		c.trackAccess(flag, false)
-		return false
+		addStep(trace, ReasonTenant, false, "tenant not configured")
+		return FlagDetail{Reason: ReasonTenantNotFound}
 	}
 
-	c.trackAccess(flag, false)
-	return false
+	addStep(trace, ReasonNotFound, true, "")
+	return FlagDetail{Reason: ReasonNotFound}
+}
+
+// authorizeStats adds the credentials of the stats to a request, or the ones of the
+// server if there are no specific ones.
+func (c *Client) authorizeStats(req *http.Request) error {
+	if c.statsAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.statsAPIKey)
+		return nil
+	}
+	return c.authorize(req)
+}
+
+// authorize adds the credentials of the client to a request to the server.
+func (c *Client) authorize(req *http.Request) error {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.idToken != nil {
+		token, err := c.idToken.Token(req)
+		if err != nil {
+			return fmt.Errorf("cannot authorize request: %w", err)
+		}
+
+		// Cloud Run accepts the token in a separate header when the application uses
+		// the standard one for its own credentials.
+		header := "Authorization"
+		if c.apiKey != "" {
+			header = "X-Serverless-Authorization"
+		}
+		req.Header.Set(header, "Bearer "+token)
+	}
+	return nil
 }
@@ -3,9 +3,12 @@ package features
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"testing/synctest"
@@ -15,7 +18,7 @@ import (
 )
 
 func initFlags() {
-	DefaultClient = &featuresClient{
+	DefaultClient = &Client{
 		flags: []flagReply{
 			{Code: "global-enabled", Enabled: true},
 			{Code: "global-disabled", Enabled: false},
@@ -41,9 +44,8 @@ func initFlags() {
 				},
 			},
 		},
-		stale:    time.Now().Add(1 * time.Minute),
-		accessCh: make(chan struct{}, 100),
-		logger:   slog.Default(),
+		stale:  time.Now().Add(1 * time.Minute),
+		logger: slog.Default(),
 	}
 }
 
@@ -65,11 +67,259 @@ func TestTenantFlags(t *testing.T) {
 	require.False(t, Flag("global-disabled-tenant-enabled", WithTenant("foo-tenant")))
 }
 
+func TestDetail(t *testing.T) {
+	initFlags()
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("global-enabled"))
+	require.Equal(t, FlagDetail{Reason: ReasonGlobal, Layer: LayerServer}, Detail("global-disabled"))
+	require.Equal(t, FlagDetail{Reason: ReasonNotFound}, Detail("not-found"))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonTenant, Layer: LayerServer}, Detail("tenant-enabled", WithTenant("foo-tenant")))
+	require.Equal(t, FlagDetail{Reason: ReasonTenantNotFound, Layer: LayerServer}, Detail("tenant-enabled", WithTenant("not-found")))
+	require.Equal(t, FlagDetail{Reason: ReasonDisabled, Layer: LayerServer}, Detail("global-disabled-tenant-enabled", WithTenant("foo-tenant")))
+}
+
+func TestEmptyTenantPolicy(t *testing.T) {
+	initFlags()
+	var buf bytes.Buffer
+	DefaultClient.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	require.Equal(t, FlagDetail{Reason: ReasonTenantMissing, Layer: LayerServer}, Detail("tenant-enabled"))
+	require.Equal(t, FlagDetail{Reason: ReasonDisabled, Layer: LayerServer}, Detail("global-disabled-tenant-enabled"))
+
+	DefaultClient.emptyTenants = EmptyTenantGlobal
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonTenantMissing, Layer: LayerServer}, Detail("tenant-enabled"))
+	require.True(t, Flag("tenant-enabled", WithTenant("")))
+	require.False(t, Flag("global-disabled-tenant-enabled"))
+	require.Empty(t, buf.String())
+
+	DefaultClient.emptyTenants = EmptyTenantWarn
+	require.Equal(t, FlagDetail{Reason: ReasonTenantMissing, Layer: LayerServer}, Detail("tenant-enabled"))
+	require.False(t, Flag("tenant-enabled"))
+	require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+	require.Equal(t, 1, strings.Count(buf.String(), "flag=tenant-enabled"))
+}
+
+func TestExcludedTenants(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "everyone-except", Enabled: true, ExcludedTenants: []string{"foo-tenant"}},
+		{Code: "tenants-except", Enabled: true, Tenants: []flagTenant{{Code: "foo-tenant", Enabled: true}}, ExcludedTenants: []string{"foo-tenant"}},
+	}
+
+	require.Equal(t, FlagDetail{Reason: ReasonTenantExcluded, Layer: LayerServer}, Detail("everyone-except", WithTenant("foo-tenant")))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("everyone-except", WithTenant("new-tenant")))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("everyone-except"))
+	require.Equal(t, FlagDetail{Reason: ReasonTenantExcluded, Layer: LayerServer}, Detail("tenants-except", WithTenant("foo-tenant")))
+}
+
+func TestArchivedFlags(t *testing.T) {
+	initFlags()
+	var buf bytes.Buffer
+	DefaultClient.logger = slog.New(slog.NewTextHandler(&buf, nil))
+	DefaultClient.flags = []flagReply{
+		{Code: "archived-enabled", Enabled: true, Archived: true, Tenants: []flagTenant{{Code: "foo-tenant", Enabled: false}}},
+		{Code: "archived-disabled", Enabled: false, Archived: true, Users: []flagTenant{{Code: "foo-user", Enabled: true}}},
+	}
+
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonArchived, Layer: LayerServer}, Detail("archived-enabled", WithTenant("foo-tenant")))
+	require.Equal(t, FlagDetail{Reason: ReasonArchived, Layer: LayerServer}, Detail("archived-disabled", WithUser("foo-user")))
+	require.True(t, Flag("archived-enabled"))
+
+	require.Equal(t, 1, strings.Count(buf.String(), "flag=archived-enabled"))
+	require.Equal(t, 1, strings.Count(buf.String(), "flag=archived-disabled"))
+}
+
+func TestFrozenFlags(t *testing.T) {
+	initFlags()
+	frozen, thawed := true, false
+	DefaultClient.flags = []flagReply{
+		{Code: "frozen-enabled", Enabled: false, Frozen: &frozen, Tenants: []flagTenant{{Code: "foo-tenant", Enabled: false}}},
+		{Code: "frozen-disabled", Enabled: true, Frozen: &thawed, ExcludedTenants: []string{"foo-tenant"}},
+	}
+
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonFrozen, Layer: LayerServer}, Detail("frozen-enabled", WithTenant("foo-tenant")))
+	require.Equal(t, FlagDetail{Reason: ReasonFrozen, Layer: LayerServer}, Detail("frozen-disabled", WithUser("foo-user")))
+}
+
+func TestEvaluationTrace(t *testing.T) {
+	initFlags()
+	DefaultClient.trace = true
+	DefaultClient.flags = []flagReply{
+		{
+			Code:            "traced",
+			Enabled:         true,
+			Tenants:         []flagTenant{{Code: "foo-tenant", Enabled: true}},
+			ExcludedTenants: []string{"bar-tenant"},
+			Users:           []flagTenant{{Code: "foo-user", Enabled: false}},
+		},
+	}
+
+	require.Equal(t, []TraceStep{
+		{Rule: ReasonTenantExcluded, Note: "tenant not excluded"},
+		{Rule: ReasonUser, Note: "user not configured"},
+		{Rule: ReasonTenant, Matched: true},
+	}, Detail("traced", WithTenant("foo-tenant"), WithUser("bar-user")).Trace)
+	require.Equal(t, []TraceStep{
+		{Rule: ReasonTenantExcluded, Matched: true},
+	}, Detail("traced", WithTenant("bar-tenant")).Trace)
+	require.Equal(t, []TraceStep{
+		{Rule: ReasonNotFound, Matched: true},
+	}, Detail("unknown").Trace)
+
+	DefaultClient.trace = false
+	require.Nil(t, Detail("traced", WithTenant("foo-tenant")).Trace)
+}
+
+func TestDefaultTenant(t *testing.T) {
+	initFlags()
+	DefaultClient.defaultTenant = "foo-tenant"
+
+	require.True(t, Flag("tenant-enabled"))
+	require.False(t, Flag("tenant-enabled", WithTenant("other-tenant")))
+	require.False(t, Flag("tenant-enabled", WithTenant("")))
+}
+
+func TestEnabled(t *testing.T) {
+	initFlags()
+	for _, code := range []string{"global-enabled", "global-disabled", "tenant-enabled", "tenant-disabled", "not-found"} {
+		require.Equal(t, Flag(code), Enabled(code, ""), code)
+		require.Equal(t, Flag(code, WithTenant("foo-tenant")), Enabled(code, "foo-tenant"), code)
+	}
+
+	DefaultClient.defaultTenant = "foo-tenant"
+	require.True(t, Enabled("tenant-enabled", ""))
+	require.False(t, Enabled("tenant-enabled", "other-tenant"))
+}
+
+func TestUserFlags(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "global-users", Enabled: true, Users: []flagTenant{{Code: "foo-user", Enabled: false}}},
+		{Code: "tenant-users", Enabled: true, Tenants: []flagTenant{{Code: "foo-tenant", Enabled: false}}, Users: []flagTenant{{Code: "foo-user", Enabled: true}}},
+		{Code: "disabled-users", Enabled: false, Users: []flagTenant{{Code: "foo-user", Enabled: true}}},
+		{Code: "excluded-users", Enabled: true, ExcludedTenants: []string{"foo-tenant"}, Users: []flagTenant{{Code: "foo-user", Enabled: true}}},
+	}
+
+	require.Equal(t, FlagDetail{Reason: ReasonUser, Layer: LayerServer}, Detail("global-users", WithUser("foo-user")))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("global-users", WithUser("bar-user")))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonUser, Layer: LayerServer}, Detail("tenant-users", WithTenant("foo-tenant"), WithUser("foo-user")))
+	require.Equal(t, FlagDetail{Reason: ReasonTenant, Layer: LayerServer}, Detail("tenant-users", WithTenant("foo-tenant"), WithUser("bar-user")))
+	require.Equal(t, FlagDetail{Reason: ReasonGlobal, Layer: LayerServer}, Detail("disabled-users", WithUser("foo-user")))
+	require.Equal(t, FlagDetail{Reason: ReasonTenantExcluded, Layer: LayerServer}, Detail("excluded-users", WithTenant("foo-tenant"), WithUser("foo-user")))
+}
+
+func TestAttributeFlags(t *testing.T) {
+	initFlags()
+	DefaultClient.trace = true
+	DefaultClient.flags = []flagReply{
+		{
+			Code:    "checkout",
+			Enabled: true,
+			Tenants: []flagTenant{{Code: "foo-tenant", Enabled: false}},
+			Users:   []flagTenant{{Code: "foo-user", Enabled: false}},
+			Rules: []flagRule{
+				{Attribute: "country", Values: []string{"ES", "PT"}, Enabled: true},
+				{Attribute: "plan", Values: []string{"free"}, Enabled: false},
+			},
+		},
+		{Code: "disabled", Enabled: false, Rules: []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: true}}},
+	}
+
+	require.Equal(t, FlagDetail{
+		Enabled: true,
+		Reason:  ReasonAttribute,
+		Layer:   LayerServer,
+		Trace:   []TraceStep{{Rule: ReasonAttribute, Matched: true, Note: "country"}},
+	}, Detail("checkout", WithTenant("foo-tenant"), WithAttribute("country", "PT"), WithAttribute("plan", "free")))
+
+	require.Equal(t, ReasonAttribute, Detail("checkout", WithTenant("foo-tenant"), WithAttribute("country", "FR"), WithAttribute("plan", "free")).Reason)
+	require.Equal(t, ReasonUser, Detail("checkout", WithTenant("foo-tenant"), WithUser("foo-user"), WithAttribute("country", "ES")).Reason)
+
+	detail := Detail("checkout", WithTenant("foo-tenant"), WithAttribute("country", "FR"))
+	require.Equal(t, ReasonTenant, detail.Reason)
+	require.Equal(t, []TraceStep{
+		{Rule: ReasonAttribute, Note: "no rule matched"},
+		{Rule: ReasonTenant, Matched: true},
+	}, detail.Trace)
+
+	detail = Detail("disabled", WithAttribute("country", "ES"))
+	require.False(t, detail.Enabled)
+	require.Equal(t, []TraceStep{
+		{Rule: ReasonAttribute, Note: "flag disabled"},
+		{Rule: ReasonGlobal, Matched: true},
+	}, detail.Trace)
+
+	require.True(t, DefaultClient.DetailAttributes("checkout", "foo-tenant", "", map[string]string{"country": "ES"}).Enabled)
+	require.False(t, DefaultClient.Detail("checkout", "foo-tenant").Enabled)
+}
+
+func TestAttributeFlagsMemo(t *testing.T) {
+	initFlags()
+	DefaultClient.rules = newRuleMemo()
+	countries := make([]string, memoRuleValues)
+	for i := range countries {
+		countries[i] = fmt.Sprintf("C%d", i)
+	}
+	DefaultClient.flags = []flagReply{
+		{Code: "checkout", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: countries, Enabled: true}}},
+		{Code: "small", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: []string{"C1"}, Enabled: true}}},
+	}
+
+	require.Equal(t, ReasonAttribute, Detail("checkout", WithAttribute("country", "C1"), WithAttribute("plan", "free")).Reason)
+	require.Equal(t, ReasonAttribute, Detail("checkout", WithAttribute("country", "C1"), WithAttribute("plan", "pro")).Reason)
+	require.Equal(t, ReasonGlobal, Detail("checkout", WithAttribute("country", "ES")).Reason)
+	require.Equal(t, ReasonAttribute, Detail("small", WithAttribute("country", "C1")).Reason)
+
+	// Only the contexts of the flags with many values are memoized, without the
+	// attributes that the rules do not check.
+	require.Len(t, DefaultClient.rules.results, 2)
+
+	// A new payload does not reuse the results of the previous one.
+	DefaultClient.flags = []flagReply{
+		{Code: "checkout", Enabled: true, Rules: []flagRule{{Attribute: "country", Values: countries, Enabled: false}}},
+	}
+	require.False(t, Detail("checkout", WithAttribute("country", "C1")).Enabled)
+}
+
+func TestAttributeFlagsMemoReset(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+
+		f := &flagReply{Code: "checkout", Rules: []flagRule{{Attribute: "country", Values: make([]string, memoRuleValues)}}}
+		DefaultClient.rules.match(f, map[string]string{"country": "ES"})
+		require.Len(t, DefaultClient.rules.results, 1)
+
+		// A new payload releases the flags of the previous one.
+		DefaultClient.fetch()
+		require.Empty(t, DefaultClient.rules.results)
+	})
+}
+
 type fakeEval struct {
 	delay time.Duration
 
 	mu       sync.Mutex
 	requests int
+	query    url.Values
+	header   http.Header
+}
+
+func (c *fakeEval) setDelay(delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delay = delay
+}
+
+func (c *fakeEval) getQuery() url.Values {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.query
+}
+
+func (c *fakeEval) getHeader() http.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.header
 }
 
 func (c *fakeEval) getRequests() int {
@@ -81,6 +331,9 @@ func (c *fakeEval) getRequests() int {
 func (c *fakeEval) RoundTrip(req *http.Request) (*http.Response, error) {
 	c.mu.Lock()
 	c.requests++
+	c.query = req.URL.Query()
+	c.header = req.Header
+	delay := c.delay
 	c.mu.Unlock()
 
 	var buf bytes.Buffer
@@ -111,7 +364,7 @@ func (c *fakeEval) RoundTrip(req *http.Request) (*http.Response, error) {
 	})
 
 	// Simulate the delay of the request.
-	time.Sleep(c.delay)
+	time.Sleep(delay)
 	if req.Context().Err() != nil {
 		return nil, req.Context().Err()
 	}
@@ -122,14 +375,29 @@ func (c *fakeEval) RoundTrip(req *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// syncBuffer collects the logs written from the background goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
 func initFetch(delay time.Duration) *fakeEval {
 	tr := &fakeEval{delay: delay}
 
 	slog.SetLogLoggerLevel(slog.LevelDebug)
-	DefaultClient = newClient("https://example.com", "foo-project", &configureOptions{
-		logger:       slog.Default(),
-		disableStats: true,
-	})
+	DefaultClient = NewClient("https://example.com", "foo-project", WithLogger(slog.Default()), WithDisableStats(true))
 	DefaultClient.local = false
 	DefaultClient.client = &http.Client{Transport: tr}
 
@@ -145,6 +413,17 @@ func TestFetch(t *testing.T) {
 	})
 }
 
+func TestFetchAPIKey(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+		DefaultClient.apiKey = "secret"
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, "Bearer secret", tr.getHeader().Get("Authorization"))
+	})
+}
+
 func TestFetchTimeout(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		initFetch(4 * time.Second)
@@ -154,6 +433,24 @@ func TestFetchTimeout(t *testing.T) {
 	})
 }
 
+func TestFetchBudget(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(2 * time.Second)
+		defer DefaultClient.Close()
+		DefaultClient.fetchBudget = 50 * time.Millisecond
+
+		start := time.Now()
+		require.False(t, Flag("global-enabled"))
+		require.Equal(t, 50*time.Millisecond, time.Since(start))
+
+		// The fetch continues in the background.
+		time.Sleep(2 * time.Second)
+		synctest.Wait()
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 1, tr.getRequests())
+	})
+}
+
 func TestFetchTimeoutWithStale(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		tr := initFetch(0)
@@ -161,7 +458,7 @@ func TestFetchTimeoutWithStale(t *testing.T) {
 
 		require.True(t, Flag("global-enabled"))
 
-		tr.delay = 4 * time.Second
+		tr.setDelay(4 * time.Second)
 		require.True(t, Flag("global-enabled"))
 	})
 }
@@ -240,6 +537,104 @@ func TestFetchProgressiveTimers(t *testing.T) {
 	})
 }
 
+func TestFetchVolatileFlags(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: fakeSources{
+			"example.com": {
+				{Code: "volatile", Enabled: true, Volatile: true},
+				{Code: "stable", Enabled: true},
+			},
+		}}
+
+		// Only stable flags in use poll slowly.
+		require.True(t, Flag("stable"))
+		time.Sleep(15 * time.Second)
+		synctest.Wait()
+		require.Equal(t, time.Minute, DefaultClient.refreshInterval)
+
+		require.True(t, Flag("volatile"))
+		time.Sleep(time.Minute)
+		synctest.Wait()
+		require.Equal(t, 15*time.Second, DefaultClient.refreshInterval)
+	})
+}
+
+func TestFetchPrefetchBeforeStale(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+		DefaultClient.staleDuration = 5 * time.Second
+		DefaultClient.maxFetchInterval = 0
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 1, tr.getRequests())
+
+		time.Sleep(4 * time.Second)
+		synctest.Wait()
+		require.Equal(t, 2, tr.getRequests())
+
+		// Without recent accesses it waits for the next tick.
+		time.Sleep(6 * time.Second)
+		synctest.Wait()
+		require.Equal(t, 2, tr.getRequests())
+	})
+}
+
+func TestFetchServeStale(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+		DefaultClient.maxStaleness = 10 * time.Minute
+		require.True(t, Flag("global-enabled"))
+
+		tr.setDelay(2 * time.Second)
+		time.Sleep(DefaultClient.maxFetchInterval)
+		DefaultClient.mu.Lock()
+		DefaultClient.stale = time.Now()
+		DefaultClient.mu.Unlock()
+
+		// The stale flags are used without waiting for the fetch.
+		start := time.Now()
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonStaleRevalidating, Layer: LayerServer}, Detail("global-enabled"))
+		require.Zero(t, time.Since(start))
+		synctest.Wait()
+		require.Equal(t, 2, tr.getRequests())
+
+		time.Sleep(2 * time.Second)
+		synctest.Wait()
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("global-enabled"))
+	})
+}
+
+func TestFetchMaxStaleness(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+		DefaultClient.maxStaleness = 3 * time.Minute
+		DefaultClient.metrics = newMetrics("http://localhost:1", "test", "foo-host")
+		var logs syncBuffer
+		DefaultClient.logger = slog.New(slog.NewTextHandler(&logs, nil))
+		require.True(t, Flag("global-enabled"))
+
+		// Fetches fail with a timeout.
+		tr.setDelay(4 * time.Second)
+		time.Sleep(3 * time.Minute)
+		require.Equal(t, FlagDetail{Reason: ReasonStale}, Detail("global-enabled"))
+		require.Equal(t, FlagDetail{Reason: ReasonStale}, Detail("global-enabled"))
+		require.Equal(t, 1, strings.Count(logs.String(), "older than the maximum staleness"))
+		require.EqualValues(t, 2, DefaultClient.metrics.staleEvaluations.Load())
+
+		tr.setDelay(0)
+		time.Sleep(time.Minute)
+		synctest.Wait()
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, Detail("global-enabled"))
+		require.Contains(t, logs.String(), "refreshed after exceeding the maximum staleness")
+	})
+}
+
 func TestFetchMaxFetchIntervalSkipsFollowUpRequests(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		tr := initFetch(0)
@@ -259,3 +654,77 @@ func TestFetchMaxFetchIntervalSkipsFollowUpRequests(t *testing.T) {
 		require.Equal(t, 2, tr.getRequests())
 	})
 }
+
+func TestFetchFlagTTL(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		DefaultClient.flagTTLs = map[string]time.Duration{"global-enabled": 12 * time.Second}
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 1, tr.getRequests())
+
+		time.Sleep(13 * time.Second)
+
+		require.False(t, Flag("global-disabled"))
+		require.Equal(t, 1, tr.getRequests())
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 2, tr.getRequests())
+	})
+}
+
+type fakeSources map[string][]flagReply
+
+func (c fakeSources) RoundTrip(req *http.Request) (*http.Response, error) {
+	flags, ok := c[req.URL.Host]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+	}
+
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(flags)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(&buf),
+	}, nil
+}
+
+func TestFetchOverrideSources(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://team.example.com", "foo-project", WithDisableStats(true), WithOverrideSource("https://platform.example.com", "platform"))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: fakeSources{
+			"team.example.com": {
+				{Code: "global-enabled", Enabled: true},
+				{Code: "team-enabled", Enabled: true},
+			},
+			"platform.example.com": {
+				{Code: "global-enabled", Enabled: false},
+				{Code: "platform-enabled", Enabled: true},
+			},
+		}}
+
+		require.False(t, Flag("global-enabled"))
+		require.True(t, Flag("team-enabled"))
+		require.True(t, Flag("platform-enabled"))
+	})
+}
+
+func TestFetchOverrideSourcesFailure(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://team.example.com", "foo-project", WithDisableStats(true), WithOverrideSource("https://platform.example.com", "platform"))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: fakeSources{
+			"team.example.com": {
+				{Code: "global-enabled", Enabled: true},
+			},
+		}}
+
+		require.False(t, Flag("global-enabled"))
+		require.False(t, DefaultClient.Ready())
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+func runBench(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	sf.register(fs)
+	qps := fs.Int("qps", 100, "Requests per second sent to the server.")
+	duration := fs.Duration("duration", time.Minute, "Duration of the test.")
+	concurrency := fs.Int("concurrency", 100, "Maximum number of requests in flight.")
+	statsRatio := fs.Float64("stats-ratio", 0.25, "Fraction of the requests that send stats instead of fetching the flags.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+	if *qps <= 0 || *concurrency <= 0 {
+		return fmt.Errorf("--qps and --concurrency should be positive")
+	}
+
+	evalURL, err := endpointURL(sf.server, "/eval", url.Values{"project": {sf.project}})
+	if err != nil {
+		return err
+	}
+	statsURL, err := endpointURL(sf.server, "/stats", nil)
+	if err != nil {
+		return err
+	}
+
+	// Stats reference the real flags of the project like the clients do.
+	flags, err := fetchFlags(ctx, sf)
+	if err != nil {
+		return err
+	}
+	b := &bench{
+		client: &http.Client{
+			Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+			Timeout:   10 * time.Second,
+		},
+		evalURL:  evalURL,
+		statsURL: statsURL,
+		apiKey:   sf.apiKey,
+		project:  sf.project,
+		flags:    flags,
+		results:  make(map[string]*benchResult),
+	}
+
+	fmt.Printf("sending %d qps to %s for %s\n", *qps, sf.server, *duration)
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+	b.run(ctx, *qps, *concurrency, *statsRatio)
+	b.print()
+
+	return nil
+}
+
+type bench struct {
+	client   *http.Client
+	evalURL  string
+	statsURL string
+	apiKey   string
+	project  string
+	flags    []flagReply
+
+	mu      sync.Mutex
+	results map[string]*benchResult
+	dropped int
+}
+
+type benchResult struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func (b *bench) run(ctx context.Context, qps, concurrency int, statsRatio float64) {
+	// Tick at most every 10ms and send the requests due since the start at once, so
+	// high rates do not depend on the resolution of the timers nor lose the
+	// fractions of the requests of each tick.
+	tick := max(time.Second/time.Duration(qps), 10*time.Millisecond)
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	start := time.Now()
+	var sent int64
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		due := int64(time.Since(start)) * int64(qps) / int64(time.Second)
+		for ; sent < due; sent++ {
+			select {
+			case sem <- struct{}{}:
+			default:
+				b.mu.Lock()
+				b.dropped++
+				b.mu.Unlock()
+				continue
+			}
+
+			wg.Go(func() {
+				defer func() { <-sem }()
+				endpoint := "eval"
+				fn := b.fetch
+				if rand.Float64() < statsRatio {
+					endpoint = "stats"
+					fn = b.sendStats
+				}
+				latency, err := fn(ctx)
+
+				// The requests canceled at the end of the run are not errors of the server.
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				b.record(endpoint, latency, err)
+			})
+		}
+	}
+}
+
+func (b *bench) record(endpoint string, latency time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result, ok := b.results[endpoint]
+	if !ok {
+		result = new(benchResult)
+		b.results[endpoint] = result
+	}
+	if err != nil {
+		result.errors++
+		return
+	}
+	result.latencies = append(result.latencies, latency)
+}
+
+func (b *bench) fetch(ctx context.Context) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.evalURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	return b.do(req)
+}
+
+// sendStats sends a batch like the ones of a client instance, with a hit for each
+// flag in the current minute.
+func (b *bench) sendStats(ctx context.Context) (time.Duration, error) {
+	bucket := time.Now().Truncate(time.Minute).UnixMilli()
+	stats := make([]map[string]any, 0, len(b.flags))
+	for _, f := range b.flags {
+		hits := rand.IntN(1000) + 1
+		stats = append(stats, map[string]any{
+			"bucket":      bucket,
+			"flag":        f.Code,
+			"enabledHits": rand.IntN(hits + 1),
+			"totalHits":   hits,
+		})
+	}
+	payload, err := json.Marshal(map[string]any{
+		"project":    b.project,
+		"instanceId": "bench-" + crand.Text(),
+		"hostname":   "features-bench",
+		"stats":      stats,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.statsURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", crand.Text())
+	return b.do(req)
+}
+
+func (b *bench) do(req *http.Request) (time.Duration, error) {
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return 0, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+func (b *bench) print() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, endpoint := range []string{"eval", "stats"} {
+		result, ok := b.results[endpoint]
+		if !ok {
+			continue
+		}
+		slices.Sort(result.latencies)
+		fmt.Printf("%-6s ok=%d errors=%d p50=%s p99=%s\n", endpoint, len(result.latencies), result.errors,
+			percentile(result.latencies, 50), percentile(result.latencies, 99))
+	}
+	if b.dropped > 0 {
+		fmt.Printf("dropped %d requests over the concurrency limit\n", b.dropped)
+	}
+}
+
+// percentile returns the percentile p of the sorted latencies.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	return latencies[(len(latencies)-1)*p/100].Round(time.Microsecond)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench(t *testing.T) {
+	var evals, stats atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eval":
+			evals.Add(1)
+			_, _ = w.Write([]byte(`[{"code": "foo", "enabled": true}]`))
+		case "/stats":
+			stats.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	b := &bench{
+		client:   server.Client(),
+		evalURL:  server.URL + "/eval",
+		statsURL: server.URL + "/stats",
+		project:  "foo",
+		flags:    []flagReply{{Code: "foo", Enabled: true}},
+		results:  make(map[string]*benchResult),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	b.run(ctx, 200, 10, 0.5)
+
+	require.NotZero(t, evals.Load())
+	require.NotZero(t, stats.Load())
+	// The requests in flight at the end of the run are canceled without a result.
+	require.LessOrEqual(t, len(b.results["eval"].latencies), int(evals.Load()))
+	require.NotEmpty(t, b.results["eval"].latencies)
+	require.Zero(t, b.results["eval"].errors)
+}
+
+func TestBenchStalledServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	b := &bench{
+		client:  server.Client(),
+		evalURL: server.URL + "/eval",
+		results: make(map[string]*benchResult),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	b.run(ctx, 100, 10, 0)
+
+	// The requests in flight are canceled with the run.
+	require.Less(t, time.Since(start), 2*time.Second)
+	require.Empty(t, b.results)
+}
+
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (tr *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tr.requests.Add(1)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+func TestBenchRate(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(countingTransport)
+		b := &bench{
+			client:  &http.Client{Transport: tr},
+			evalURL: "http://example.com/eval",
+			results: make(map[string]*benchResult),
+		}
+
+		// The rate does not fit a whole number of requests in each tick.
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second+time.Millisecond)
+		defer cancel()
+		b.run(ctx, 150, 10, 0)
+
+		require.EqualValues(t, 300, tr.requests.Load())
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := range 10 {
+		latencies = append(latencies, time.Duration(i+1)*time.Millisecond)
+	}
+	require.Equal(t, 5*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 9*time.Millisecond, percentile(latencies, 99))
+	require.Zero(t, percentile(nil, 99))
+}
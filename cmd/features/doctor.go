@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func runDoctor(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	sf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+
+	var failed bool
+	check := func(name string, fn func() (string, error)) {
+		start := time.Now()
+		result, err := fn()
+		if err != nil {
+			failed = true
+			fmt.Printf("FAIL  %s (%s)\n      %s\n", name, time.Since(start).Round(time.Millisecond), err)
+			return
+		}
+		if result != "" {
+			result = ": " + result
+		}
+		fmt.Printf("OK    %s (%s)%s\n", name, time.Since(start).Round(time.Millisecond), result)
+	}
+	accepted := func(result string) string {
+		if sf.apiKey == "" {
+			return result
+		}
+		if result == "" {
+			return "credentials accepted"
+		}
+		return result + ", credentials accepted"
+	}
+
+	check("eval endpoint", func() (string, error) {
+		u, err := endpointURL(sf.server, "/eval", url.Values{"project": {sf.project}})
+		if err != nil {
+			return "", fmt.Errorf("%w: check the --server flag", err)
+		}
+		body, err := doctorRequest(ctx, http.MethodGet, u, sf.apiKey, nil)
+		if err != nil {
+			return "", err
+		}
+
+		var flags []flagReply
+		if err := json.Unmarshal(body, &flags); err != nil {
+			return "", fmt.Errorf("cannot decode the flags: %w: check the server version is compatible with this client", err)
+		}
+		return accepted(fmt.Sprintf("%d flags", len(flags))), nil
+	})
+
+	check("stats endpoint", func() (string, error) {
+		u, err := endpointURL(sf.server, "/stats", nil)
+		if err != nil {
+			return "", fmt.Errorf("%w: check the --server flag", err)
+		}
+		payload, err := json.Marshal(map[string]any{
+			"project": sf.project,
+			"stats":   []any{},
+		})
+		if err != nil {
+			return "", err
+		}
+		if _, err := doctorRequest(ctx, http.MethodPost, u, sf.apiKey, payload); err != nil {
+			return "", err
+		}
+		return accepted(""), nil
+	})
+
+	if failed {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
+
+func doctorRequest(ctx context.Context, method, u, apiKey string, payload []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect: %w: check the server URL and the network access to it", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return body, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if apiKey == "" {
+			return nil, fmt.Errorf("status code %d: the server requires credentials: pass the --api-key flag or set $FEATURES_API_KEY", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("status code %d: the server rejected the API key", resp.StatusCode)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("status code %d: check the server URL points to the features server and the project exists", resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
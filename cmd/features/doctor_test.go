@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctorRequestCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foo-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	body, err := doctorRequest(context.Background(), http.MethodGet, server.URL, "foo-key", nil)
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(body))
+
+	_, err = doctorRequest(context.Background(), http.MethodGet, server.URL, "bar-key", nil)
+	require.EqualError(t, err, "status code 401: the server rejected the API key")
+
+	_, err = doctorRequest(context.Background(), http.MethodGet, server.URL, "", nil)
+	require.EqualError(t, err, "status code 401: the server requires credentials: pass the --api-key flag or set $FEATURES_API_KEY")
+}
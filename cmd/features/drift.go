@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	featuresImport  = "github.com/altipla-consulting/features-go"
+	generatedHeader = "// Code generated by features generate; DO NOT EDIT."
+)
+
+// Kinds of flags compared between the code and the server.
+const (
+	kindBool       = "bool"
+	kindPercentage = "percentage"
+	kindNumber     = "number"
+	kindString     = "string"
+
+	// kindValue is a flag read with Value or JSON with a type that accepts any value.
+	kindValue = "value"
+
+	// kindJSON is a value of the server that is not a number nor a string.
+	kindJSON = "json"
+)
+
+func runDrift(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	sf.register(fs)
+	ignoreExtra := fs.Bool("ignore-extra", false, "Do not report flags of the server that the code does not declare, for projects shared by multiple services.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"./..."}
+	}
+
+	declared, err := scanDeclarations(dirs)
+	if err != nil {
+		return err
+	}
+	flags, err := fetchFlags(ctx, sf)
+	if err != nil {
+		return err
+	}
+
+	entries := compareDrift(declared, flags)
+	if *ignoreExtra {
+		entries = slices.DeleteFunc(entries, func(entry driftEntry) bool { return entry.status == driftExtra })
+	}
+	printDrift(os.Stdout, entries)
+	if len(entries) > 0 {
+		return fmt.Errorf("found %d differences between the code and the server", len(entries))
+	}
+	return nil
+}
+
+// declaration is a flag referenced by the code.
+type declaration struct {
+	code string
+	kind string
+	pos  token.Position
+}
+
+// scanDeclarations parses the Go files of the directories and returns the flags
+// declared in the manifests generated by the generate command and in the calls to
+// Register, Define, Percentage, Value, ClientValue and JSON with constant codes.
+// Directories ending in "/..." are scanned recursively.
+func scanDeclarations(dirs []string) (map[string]*declaration, error) {
+	var files []string
+	for _, dir := range dirs {
+		root, recursive := strings.CutSuffix(dir, "/...")
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && (!recursive || skipDir(d.Name())) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".go") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan %s: %w", dir, err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", file, err)
+		}
+		parsed = append(parsed, f)
+	}
+
+	declared := make(map[string]*declaration)
+	declare := func(code, kind string, pos token.Pos) {
+		if d, ok := declared[code]; ok {
+			// Any usage with a value makes the flag a value, and any percentage usage
+			// makes it a percentage.
+			if d.kind == kindBool || kind == kindPercentage {
+				d.kind = kind
+			}
+			return
+		}
+		declared[code] = &declaration{code: code, kind: kind, pos: fset.Position(pos)}
+	}
+
+	// Constants of the generated manifests, to resolve them when they are used as
+	// arguments of the calls.
+	constants := make(map[string]string)
+	for _, f := range parsed {
+		if !generatedManifest(f) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						continue
+					}
+					if code, ok := stringLiteral(vs.Values[i]); ok {
+						constants[name.Name] = code
+						declare(code, kindBool, name.Pos())
+					}
+				}
+			}
+		}
+	}
+
+	for _, f := range parsed {
+		pkg := featuresPackageName(f)
+		if pkg == "" {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			// Generic calls like features.Value[int](...) have the type argument in an
+			// index expression around the function.
+			fun, typeArg := call.Fun, ast.Expr(nil)
+			if index, ok := fun.(*ast.IndexExpr); ok {
+				fun, typeArg = index.X, index.Index
+			}
+			sel, ok := fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if ident, ok := sel.X.(*ast.Ident); !ok || ident.Name != pkg {
+				return true
+			}
+
+			switch sel.Sel.Name {
+			case "Register":
+				for _, arg := range call.Args {
+					if code, ok := resolveCode(arg, constants); ok {
+						declare(code, kindBool, arg.Pos())
+					}
+				}
+			case "Define":
+				if len(call.Args) > 0 {
+					if code, ok := resolveCode(call.Args[0], constants); ok {
+						declare(code, kindBool, call.Args[0].Pos())
+					}
+				}
+			case "Percentage":
+				if len(call.Args) > 0 {
+					if code, ok := resolveCode(call.Args[0], constants); ok {
+						declare(code, kindPercentage, call.Args[0].Pos())
+					}
+				}
+			case "Value":
+				if len(call.Args) > 0 {
+					if code, ok := resolveCode(call.Args[0], constants); ok {
+						declare(code, valueKind(typeArg), call.Args[0].Pos())
+					}
+				}
+			case "ClientValue":
+				if len(call.Args) > 1 {
+					if code, ok := resolveCode(call.Args[1], constants); ok {
+						declare(code, valueKind(typeArg), call.Args[1].Pos())
+					}
+				}
+			case "JSON":
+				if len(call.Args) > 0 {
+					if code, ok := resolveCode(call.Args[0], constants); ok {
+						declare(code, kindValue, call.Args[0].Pos())
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	return declared, nil
+}
+
+func skipDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func generatedManifest(f *ast.File) bool {
+	return len(f.Comments) > 0 && len(f.Comments[0].List) > 0 && f.Comments[0].List[0].Text == generatedHeader
+}
+
+// featuresPackageName returns the name of the features package in the file, or
+// an empty string if it is not imported.
+func featuresPackageName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path != featuresImport {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "features"
+	}
+	return ""
+}
+
+// resolveCode returns the code of a string literal or a constant of a generated
+// manifest, like flags.NewCheckout.
+func resolveCode(expr ast.Expr, constants map[string]string) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return stringLiteral(expr)
+	case *ast.Ident:
+		code, ok := constants[expr.Name]
+		return code, ok
+	case *ast.SelectorExpr:
+		code, ok := constants[expr.Sel.Name]
+		return code, ok
+	}
+	return "", false
+}
+
+// valueKind returns the kind of the values decoded as the type argument of Value.
+// Types that are not explicit or not basic types accept any value.
+func valueKind(typeArg ast.Expr) string {
+	switch typeArg := typeArg.(type) {
+	case *ast.Ident:
+		switch typeArg.Name {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+			return kindNumber
+		case "string":
+			return kindString
+		}
+	case *ast.SelectorExpr:
+		// Durations are configured in the server as strings like "1m30s".
+		if pkg, ok := typeArg.X.(*ast.Ident); ok && pkg.Name == "time" && typeArg.Sel.Name == "Duration" {
+			return kindString
+		}
+	}
+	return kindValue
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+type driftStatus string
+
+const (
+	driftMissing  driftStatus = "missing"
+	driftExtra    driftStatus = "extra"
+	driftMismatch driftStatus = "mismatch"
+)
+
+type driftEntry struct {
+	status driftStatus
+	code   string
+	detail string
+}
+
+// compareDrift returns the differences between the declared flags and the flags of
+// the server, sorted by code.
+func compareDrift(declared map[string]*declaration, flags []flagReply) []driftEntry {
+	var entries []driftEntry
+	server := make(map[string]flagReply)
+	for _, f := range flags {
+		server[f.Code] = f
+		if _, ok := declared[f.Code]; !ok {
+			entries = append(entries, driftEntry{
+				status: driftExtra,
+				code:   f.Code,
+				detail: "in the server but not declared in the code",
+			})
+		}
+	}
+	for code, d := range declared {
+		f, ok := server[code]
+		if !ok {
+			entries = append(entries, driftEntry{
+				status: driftMissing,
+				code:   code,
+				detail: fmt.Sprintf("declared in %s but not in the server", d.pos),
+			})
+			continue
+		}
+		if kind := serverKind(f); !compatibleKind(d.kind, kind) {
+			entries = append(entries, driftEntry{
+				status: driftMismatch,
+				code:   code,
+				detail: fmt.Sprintf("declared as %s in %s but the server has a %s", d.kind, d.pos, kind),
+			})
+		}
+	}
+	slices.SortFunc(entries, func(a, b driftEntry) int {
+		return strings.Compare(a.code, b.code)
+	})
+	return entries
+}
+
+// serverKind returns the kind of the value of the flag in the server. Only the
+// numbers between 0 and 100 are percentages.
+func serverKind(f flagReply) string {
+	if len(f.Value) == 0 {
+		return kindBool
+	}
+	var number float64
+	if json.Unmarshal(f.Value, &number) == nil {
+		if number >= 0 && number <= 100 {
+			return kindPercentage
+		}
+		return kindNumber
+	}
+	var s string
+	if json.Unmarshal(f.Value, &s) == nil {
+		return kindString
+	}
+	return kindJSON
+}
+
+// compatibleKind reports if a flag declared with a kind in the code can be read
+// from a flag of the server of the other kind.
+func compatibleKind(declared, server string) bool {
+	switch declared {
+	case kindNumber:
+		return server == kindNumber || server == kindPercentage
+	case kindValue:
+		return server != kindBool
+	}
+	return declared == server
+}
+
+func printDrift(w io.Writer, entries []driftEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No differences between the code and the server.")
+		return
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%-8s %s: %s\n", entry.status, entry.code, entry.detail)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeDriftFiles(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"flags/flags.go": `// Code generated by features generate; DO NOT EDIT.
+
+// Package flags contains the feature flags of the project foo.
+package flags
+
+const (
+	// NewCheckout is the code of the flag new-checkout.
+	NewCheckout = "new-checkout"
+)
+`,
+		"main.go": `package main
+
+import (
+	"time"
+
+	ff "github.com/altipla-consulting/features-go"
+
+	"example.com/foo/flags"
+)
+
+func init() {
+	ff.Register(flags.NewCheckout, "dark-mode")
+	ff.Define("kill-switch", true)
+}
+
+func main() {
+	_ = ff.Percentage("sampling", 10)
+	_ = ff.Value[int]("max-items", 10)
+	_ = ff.Value[time.Duration]("timeout", time.Second)
+	_ = ff.ClientValue[string](ff.DefaultClient, "banner", "")
+	_ = ff.Value("retries", 3)
+
+	var pricing struct{ Discount int }
+	_ = ff.JSON("pricing", &pricing)
+}
+`,
+		"other/other.go": `package other
+
+import "example.com/features"
+
+func init() {
+	features.Register("ignored")
+}
+`,
+		"vendor/lib/lib.go": `package lib
+
+import "github.com/altipla-consulting/features-go"
+
+func init() {
+	features.Register("vendored")
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestScanDeclarations(t *testing.T) {
+	dir := writeDriftFiles(t)
+
+	declared, err := scanDeclarations([]string{dir + "/..."})
+	require.NoError(t, err)
+
+	kinds := make(map[string]string)
+	for code, d := range declared {
+		kinds[code] = d.kind
+	}
+	require.Equal(t, map[string]string{
+		"new-checkout": kindBool,
+		"dark-mode":    kindBool,
+		"kill-switch":  kindBool,
+		"sampling":     kindPercentage,
+		"max-items":    kindNumber,
+		"timeout":      kindString,
+		"banner":       kindString,
+		"retries":      kindValue,
+		"pricing":      kindValue,
+	}, kinds)
+
+	declared, err = scanDeclarations([]string{dir})
+	require.NoError(t, err)
+	require.Len(t, declared, 8)
+}
+
+func TestCompareDrift(t *testing.T) {
+	declared, err := scanDeclarations([]string{writeDriftFiles(t) + "/..."})
+	require.NoError(t, err)
+
+	flags := []flagReply{
+		{Code: "new-checkout", Enabled: true},
+		{Code: "dark-mode", Value: json.RawMessage(`50`)},
+		{Code: "sampling", Value: json.RawMessage(`25`)},
+		{Code: "max-items", Value: json.RawMessage(`500`)},
+		{Code: "timeout", Value: json.RawMessage(`"30s"`)},
+		{Code: "banner", Value: json.RawMessage(`"Sale"`)},
+		{Code: "retries", Value: json.RawMessage(`5`)},
+		{Code: "pricing", Value: json.RawMessage(`{"discount": 10}`)},
+		{Code: "old-flag"},
+	}
+	entries := compareDrift(declared, flags)
+
+	var statuses []string
+	for _, entry := range entries {
+		statuses = append(statuses, string(entry.status)+" "+entry.code)
+	}
+	require.Equal(t, []string{
+		"mismatch dark-mode",
+		"missing kill-switch",
+		"extra old-flag",
+	}, statuses)
+	require.Contains(t, entries[1].detail, "main.go:13")
+
+	var out strings.Builder
+	printDrift(&out, nil)
+	require.Equal(t, "No differences between the code and the server.\n", out.String())
+}
+
+func TestCompareDriftValues(t *testing.T) {
+	declared, err := scanDeclarations([]string{writeDriftFiles(t) + "/..."})
+	require.NoError(t, err)
+
+	flags := []flagReply{
+		{Code: "new-checkout"},
+		{Code: "dark-mode"},
+		{Code: "kill-switch"},
+		{Code: "sampling", Value: json.RawMessage(`250`)},
+		{Code: "max-items", Value: json.RawMessage(`"many"`)},
+		{Code: "timeout", Value: json.RawMessage(`30`)},
+		{Code: "banner", Value: json.RawMessage(`"Sale"`)},
+		{Code: "retries"},
+		{Code: "pricing", Value: json.RawMessage(`[1, 2]`)},
+	}
+	entries := compareDrift(declared, flags)
+
+	var statuses []string
+	for _, entry := range entries {
+		statuses = append(statuses, string(entry.status)+" "+entry.code)
+	}
+	require.Equal(t, []string{
+		"mismatch max-items",
+		"mismatch retries",
+		"mismatch sampling",
+		"mismatch timeout",
+	}, statuses)
+	require.Contains(t, entries[2].detail, "declared as percentage")
+	require.Contains(t, entries[2].detail, "but the server has a number")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+func runEval(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	sf.register(fs)
+	tenant := fs.String("tenant", "", "Tenant to evaluate the flag for.")
+	user := fs.String("user", "", "User to evaluate the flag for.")
+	attributes := make(attributeFlags)
+	fs.Var(attributes, "attr", "Attribute for the targeting rules as key=value. It can be repeated.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one flag code to evaluate")
+	}
+	code := fs.Arg(0)
+
+	client := features.NewClient(sf.server, sf.project, features.WithLocal(false), features.WithDisableStats(true), features.WithAPIKey(sf.apiKey))
+	defer client.Close()
+
+	// Without the flags every evaluation would be reported as not found.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := client.WaitForReady(waitCtx); err != nil {
+		return fmt.Errorf("cannot fetch flags: %w", err)
+	}
+
+	detail := client.DetailAttributes(code, *tenant, *user, attributes)
+	state := "disabled"
+	if detail.Enabled {
+		state = "enabled"
+	}
+	fmt.Printf("%s: %s (%s)\n", code, state, detail.Reason)
+
+	return nil
+}
+
+// attributeFlags collects the repeated key=value attributes of the command line.
+type attributeFlags map[string]string
+
+func (a attributeFlags) String() string {
+	var pairs []string
+	for key, value := range a {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (a attributeFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid attribute %q, expected key=value", s)
+	}
+	a[key] = value
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type flagReply struct {
+	Code    string       `json:"code"`
+	Enabled bool         `json:"enabled"`
+	Tenants []flagTenant `json:"tenants"`
+
+	// Value of the flags that are not only booleans, like percentages.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type flagTenant struct {
+	Code    string `json:"code"`
+	Enabled bool   `json:"enabled"`
+}
+
+// authorize adds the API key, if any, to a request to the server.
+func (sf serverFlags) authorize(req *http.Request) {
+	if sf.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+sf.apiKey)
+	}
+}
+
+func endpointURL(server, endpoint string, qs url.Values) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse server url: %w", err)
+	}
+	u.Path += endpoint
+	u.RawQuery = qs.Encode()
+	return u.String(), nil
+}
+
+// fetchFlags downloads the raw flags of the project from the server.
+func fetchFlags(ctx context.Context, sf serverFlags) ([]flagReply, error) {
+	u, err := endpointURL(sf.server, "/eval", url.Values{"project": {sf.project}})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request: %w", err)
+	}
+	sf.authorize(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch flags: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var flags []flagReply
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, fmt.Errorf("cannot decode flags: %w", err)
+	}
+	return flags, nil
+}
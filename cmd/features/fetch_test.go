@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchFlagsAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foo-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"code": "foo", "enabled": true}]`))
+	}))
+	defer server.Close()
+
+	flags, err := fetchFlags(context.Background(), serverFlags{server: server.URL, project: "foo", apiKey: "foo-key"})
+	require.NoError(t, err)
+	require.Equal(t, []flagReply{{Code: "foo", Enabled: true}}, flags)
+
+	_, err = fetchFlags(context.Background(), serverFlags{server: server.URL, project: "foo"})
+	require.EqualError(t, err, "unexpected status code 401")
+}
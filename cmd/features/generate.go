@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+func runGenerate(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	sf.register(fs)
+	pkg := fs.String("package", "flags", "Name of the generated Go package.")
+	output := fs.String("output", "", "File to write the generated code. Defaults to stdout.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+
+	flags, err := fetchFlags(ctx, sf)
+	if err != nil {
+		return err
+	}
+	codes := make([]string, 0, len(flags))
+	for _, f := range flags {
+		codes = append(codes, f.Code)
+	}
+
+	src, err := generateConstants(*pkg, sf.project, codes)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*output, src, 0600)
+}
+
+func generateConstants(pkg, project string, codes []string) ([]byte, error) {
+	codes = slices.Clone(codes)
+	slices.Sort(codes)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by features generate; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "// Package %s contains the feature flags of the project %s.\n", pkg, project)
+	fmt.Fprintf(&buf, "package %s\n", pkg)
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "const (")
+	idents := make(map[string]string)
+	for _, code := range codes {
+		ident := flagIdent(code)
+		if prev, ok := idents[ident]; ok {
+			return nil, fmt.Errorf("flags %q and %q generate the same identifier %s", prev, code, ident)
+		}
+		idents[ident] = code
+
+		fmt.Fprintf(&buf, "// %s is the code of the flag %s.\n", ident, code)
+		fmt.Fprintf(&buf, "%s = %q\n", ident, code)
+	}
+	fmt.Fprintln(&buf, ")")
+
+	return format.Source(buf.Bytes())
+}
+
+// flagIdent converts a flag code like "new-checkout" to an exported Go identifier
+// like "NewCheckout".
+func flagIdent(code string) string {
+	var ident strings.Builder
+	upper := true
+	for _, r := range code {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		ident.WriteRune(r)
+	}
+
+	if ident.Len() == 0 || !unicode.IsLetter([]rune(ident.String())[0]) {
+		return "Flag" + ident.String()
+	}
+	return ident.String()
+}
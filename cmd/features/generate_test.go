@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagIdent(t *testing.T) {
+	require.Equal(t, "NewCheckout", flagIdent("new-checkout"))
+	require.Equal(t, "NewCheckout", flagIdent("new_checkout"))
+	require.Equal(t, "Flag2faLogin", flagIdent("2fa-login"))
+	require.Equal(t, "Flag", flagIdent("---"))
+}
+
+func TestGenerateConstantsCollision(t *testing.T) {
+	_, err := generateConstants("flags", "foo", []string{"new-checkout", "new_checkout"})
+	require.Error(t, err)
+}
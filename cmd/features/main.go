@@ -0,0 +1,86 @@
+// Command features is a command line tool to inspect the feature flags of a project.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "bench", usage: "Send synthetic load to the server.", run: runBench},
+	{name: "doctor", usage: "Check the connection with the server.", run: runDoctor},
+	{name: "drift", usage: "Compare the flags declared in the code with the server.", run: runDrift},
+	{name: "eval", usage: "Evaluate a flag with the live server data.", run: runEval},
+	{name: "generate", usage: "Generate Go constants for the flags of a project.", run: runGenerate},
+	{name: "repl", usage: "Evaluate flags interactively with a snapshot.", run: runREPL},
+	{name: "replay", usage: "Evaluate recorded contexts with an exported snapshot.", run: runReplay},
+	{name: "watch", usage: "Print the flag changes of a project as they happen.", run: runWatch},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+
+		if err := cmd.run(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "features %s: %s\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "features: unknown command %q\n", os.Args[1])
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: features <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// serverFlags registers the flags shared by all the commands that connect to the server.
+type serverFlags struct {
+	server  string
+	project string
+	apiKey  string
+}
+
+func (sf *serverFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&sf.server, "server", os.Getenv("FEATURES_SERVER_URL"), "URL of the features server. Defaults to $FEATURES_SERVER_URL.")
+	fs.StringVar(&sf.project, "project", os.Getenv("FEATURES_PROJECT"), "Project of the flags. Defaults to $FEATURES_PROJECT.")
+	fs.StringVar(&sf.apiKey, "api-key", os.Getenv("FEATURES_API_KEY"), "API key of the server. Defaults to $FEATURES_API_KEY.")
+}
+
+func (sf *serverFlags) validate() error {
+	if sf.server == "" {
+		return fmt.Errorf("missing --server flag")
+	}
+	if sf.project == "" {
+		return fmt.Errorf("missing --project flag")
+	}
+	return nil
+}
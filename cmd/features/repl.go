@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+func runREPL(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	sf.register(fs)
+	snapshot := fs.String("snapshot", "", "File with the state exported from a client with Export. Defaults to a live snapshot of the server.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := loadSnapshot(ctx, sf, *snapshot)
+	if err != nil {
+		return err
+	}
+	client, err := features.NewStaticClient(data, features.WithEvaluationTrace(true))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var state struct {
+		Flags []flagReply `json:"flags"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("cannot decode snapshot: %w", err)
+	}
+	codes := make([]string, 0, len(state.Flags))
+	for _, f := range state.Flags {
+		codes = append(codes, f.Code)
+	}
+	slices.Sort(codes)
+
+	fmt.Printf("loaded %d flags, type help for the commands\n", len(codes))
+	return repl(client, codes, os.Stdin, os.Stdout)
+}
+
+// loadSnapshot reads the exported snapshot, or exports the live flags of the server
+// if there is no file.
+func loadSnapshot(ctx context.Context, sf serverFlags, path string) ([]byte, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snapshot: %w", err)
+		}
+		return data, nil
+	}
+
+	if err := sf.validate(); err != nil {
+		return nil, err
+	}
+	live := features.NewClient(sf.server, sf.project, features.WithLocal(false), features.WithDisableStats(true), features.WithAPIKey(sf.apiKey))
+	defer live.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := live.WaitForReady(ctx); err != nil {
+		return nil, fmt.Errorf("cannot fetch flags: %w", err)
+	}
+	return live.Export()
+}
+
+const replHelp = `Commands:
+  <flag> [tenant=<tenant>] [user=<user>] [<attr>=<value>...]  Evaluate a flag and print the trace.
+  tenant [<tenant>]                                           Set or clear the tenant of the next evaluations.
+  user [<user>]                                               Set or clear the user of the next evaluations.
+  attr [<attr>[=<value>]]                                     Set or clear an attribute, or all of them, of the next evaluations.
+  flags                                                       List the flags of the snapshot.
+  help                                                        Print this help.
+  exit                                                        Exit the REPL.
+`
+
+// repl evaluates the flags of the client interactively reading commands from in.
+func repl(client *features.Client, codes []string, in io.Reader, out io.Writer) error {
+	var tenant, user string
+	attributes := make(map[string]string)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "exit", "quit":
+			return nil
+
+		case "help":
+			fmt.Fprint(out, replHelp)
+
+		case "flags":
+			for _, code := range codes {
+				fmt.Fprintln(out, code)
+			}
+
+		case "tenant":
+			tenant = ""
+			if len(fields) > 1 {
+				tenant = fields[1]
+			}
+
+		case "user":
+			user = ""
+			if len(fields) > 1 {
+				user = fields[1]
+			}
+
+		case "attr":
+			if len(fields) == 1 {
+				clear(attributes)
+				continue
+			}
+			for _, arg := range fields[1:] {
+				key, value, ok := strings.Cut(arg, "=")
+				if ok {
+					attributes[key] = value
+				} else {
+					delete(attributes, key)
+				}
+			}
+
+		default:
+			evalTenant, evalUser := tenant, user
+			evalAttributes := maps.Clone(attributes)
+			var invalid bool
+			for _, arg := range fields[1:] {
+				key, value, ok := strings.Cut(arg, "=")
+				switch {
+				case !ok || key == "":
+					fmt.Fprintf(out, "unknown argument %q, type help for the commands\n", arg)
+					invalid = true
+				case key == "tenant":
+					evalTenant = value
+				case key == "user":
+					evalUser = value
+				default:
+					evalAttributes[key] = value
+				}
+			}
+			if invalid {
+				continue
+			}
+			printEvaluation(out, client, fields[0], evalTenant, evalUser, evalAttributes)
+		}
+	}
+}
+
+func printEvaluation(w io.Writer, client *features.Client, code, tenant, user string, attributes map[string]string) {
+	detail := client.DetailAttributes(code, tenant, user, attributes)
+	state := "disabled"
+	if detail.Enabled {
+		state = "enabled"
+	}
+	fmt.Fprintf(w, "%s: %s (%s)\n", code, state, detail.Reason)
+
+	if detail.Layer != features.LayerServer {
+		if detail.Layer != "" {
+			fmt.Fprintf(w, "  - overridden by the %s layer\n", detail.Layer)
+		}
+		return
+	}
+	for _, step := range detail.Trace {
+		switch {
+		case step.Matched && step.Note != "":
+			fmt.Fprintf(w, "  - %s: matched %s\n", step.Rule, step.Note)
+		case step.Matched:
+			fmt.Fprintf(w, "  - %s: matched\n", step.Rule)
+		default:
+			fmt.Fprintf(w, "  - %s: %s\n", step.Rule, step.Note)
+		}
+	}
+}
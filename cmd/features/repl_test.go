@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+const replSnapshot = `{
+	"project": "foo",
+	"flags": [
+		{"code": "new-checkout", "enabled": true, "tenants": [{"code": "acme", "enabled": true}], "excludedTenants": ["banned"]},
+		{"code": "beta", "enabled": true, "users": [{"code": "u1", "enabled": false}]},
+		{"code": "killed", "enabled": true, "killed": true},
+		{"code": "pricing", "enabled": true, "rules": [{"attribute": "country", "values": ["ES"], "enabled": true}], "tenants": [{"code": "acme", "enabled": false}]}
+	],
+	"overrides": {"TEST": {"forced": true}}
+}`
+
+func TestREPL(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(replSnapshot), features.WithEvaluationTrace(true))
+	require.NoError(t, err)
+	defer client.Close()
+
+	in := strings.NewReader(`flags
+new-checkout tenant=acme
+tenant other
+new-checkout
+beta user=u1
+killed
+forced
+pricing country=ES
+attr country=ES plan=pro
+pricing
+pricing country=FR
+attr country
+pricing
+attr
+pricing plan
+exit
+new-checkout
+`)
+	var out strings.Builder
+	require.NoError(t, repl(client, []string{"beta", "killed", "new-checkout"}, in, &out))
+
+	require.Equal(t, `> beta
+killed
+new-checkout
+> new-checkout: enabled (TENANT)
+  - TENANT_EXCLUDED: tenant not excluded
+  - TENANT: matched
+> > new-checkout: disabled (TENANT_NOT_FOUND)
+  - TENANT_EXCLUDED: tenant not excluded
+  - TENANT: tenant not configured
+> beta: disabled (USER)
+  - USER: matched
+> killed: disabled (KILLED)
+  - KILLED: matched
+> forced: enabled (OVERRIDE)
+  - overridden by the TEST layer
+> pricing: enabled (ATTRIBUTE)
+  - ATTRIBUTE: matched country
+> > pricing: enabled (ATTRIBUTE)
+  - ATTRIBUTE: matched country
+> pricing: disabled (TENANT_NOT_FOUND)
+  - ATTRIBUTE: no rule matched
+  - TENANT: tenant not configured
+> > pricing: disabled (TENANT_NOT_FOUND)
+  - ATTRIBUTE: no rule matched
+  - TENANT: tenant not configured
+> > unknown argument "plan", type help for the commands
+> `, out.String())
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+func runReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	snapshot := fs.String("snapshot", "", "File with the state exported from a client with Export.")
+	format := fs.String("format", "", "Format of the evaluations: jsonl or csv. Defaults to the extension of the file.")
+	output := fs.String("output", "", "File to write the result of each evaluation as JSON lines.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *snapshot == "" {
+		return fmt.Errorf("missing --snapshot flag")
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one file with the evaluations")
+	}
+
+	data, err := os.ReadFile(*snapshot)
+	if err != nil {
+		return fmt.Errorf("cannot read snapshot: %w", err)
+	}
+	client, err := features.NewStaticClient(data)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("cannot open evaluations: %w", err)
+	}
+	defer f.Close()
+	if *format == "" {
+		*format = "jsonl"
+		if filepath.Ext(fs.Arg(0)) == ".csv" {
+			*format = "csv"
+		}
+	}
+
+	results := io.Discard
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("cannot create output: %w", err)
+		}
+		defer out.Close()
+		results = out
+	}
+
+	summary, err := replay(client, f, *format, results)
+	if err != nil {
+		return err
+	}
+	for _, s := range summary {
+		fmt.Printf("%s: enabled %d of %d (%.1f%%)\n", s.Flag, s.Enabled, s.Total, float64(s.Enabled)*100/float64(s.Total))
+	}
+	return nil
+}
+
+// evaluationContext is a single evaluation to replay.
+type evaluationContext struct {
+	Flag       string            `json:"flag"`
+	Tenant     string            `json:"tenant,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type evaluationResult struct {
+	evaluationContext
+	Enabled bool            `json:"enabled"`
+	Reason  features.Reason `json:"reason"`
+}
+
+type replaySummary struct {
+	Flag    string
+	Enabled int
+	Total   int
+}
+
+// replay evaluates each context of the input with the client, writing each result
+// to w, and returns the number of evaluations enabled for each flag.
+func replay(client *features.Client, r io.Reader, format string, w io.Writer) ([]replaySummary, error) {
+	var next func() (evaluationContext, error)
+	switch format {
+	case "jsonl":
+		decoder := json.NewDecoder(bufio.NewReader(r))
+		next = func() (evaluationContext, error) {
+			var eval evaluationContext
+			err := decoder.Decode(&eval)
+			return eval, err
+		}
+
+	case "csv":
+		// Columns are flag, tenant and user, with an optional header. The rest of the
+		// columns are attributes written as key=value.
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header := true
+		next = func() (evaluationContext, error) {
+			record, err := reader.Read()
+			if err != nil {
+				return evaluationContext{}, err
+			}
+			if header && record[0] == "flag" {
+				if record, err = reader.Read(); err != nil {
+					return evaluationContext{}, err
+				}
+			}
+			header = false
+			eval := evaluationContext{Flag: record[0]}
+			if len(record) > 1 {
+				eval.Tenant = record[1]
+			}
+			if len(record) > 2 {
+				eval.User = record[2]
+			}
+			for _, attr := range record[min(len(record), 3):] {
+				if attr == "" {
+					continue
+				}
+				key, value, ok := strings.Cut(attr, "=")
+				if !ok {
+					return evaluationContext{}, fmt.Errorf("invalid attribute %q, expected key=value", attr)
+				}
+				if eval.Attributes == nil {
+					eval.Attributes = make(map[string]string)
+				}
+				eval.Attributes[key] = value
+			}
+			return eval, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	encoder := json.NewEncoder(w)
+	index := make(map[string]int)
+	var summary []replaySummary
+	for {
+		eval, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read evaluation: %w", err)
+		}
+		if eval.Flag == "" {
+			return nil, fmt.Errorf("evaluation without flag")
+		}
+
+		detail := client.DetailAttributes(eval.Flag, eval.Tenant, eval.User, eval.Attributes)
+		if err := encoder.Encode(evaluationResult{eval, detail.Enabled, detail.Reason}); err != nil {
+			return nil, fmt.Errorf("cannot write result: %w", err)
+		}
+
+		i, ok := index[eval.Flag]
+		if !ok {
+			i = len(summary)
+			index[eval.Flag] = i
+			summary = append(summary, replaySummary{Flag: eval.Flag})
+		}
+		summary[i].Total++
+		if detail.Enabled {
+			summary[i].Enabled++
+		}
+	}
+
+	slices.SortFunc(summary, func(a, b replaySummary) int {
+		return cmp.Compare(a.Flag, b.Flag)
+	})
+	return summary, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+const replaySnapshot = `{
+	"project": "foo",
+	"flags": [
+		{"code": "new-checkout", "enabled": true, "tenants": [{"code": "acme", "enabled": true}]},
+		{"code": "dark-mode", "enabled": true},
+		{"code": "pricing", "enabled": true, "rules": [{"attribute": "country", "values": ["ES"], "enabled": false}]}
+	]
+}`
+
+func TestReplayJSONL(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(replaySnapshot))
+	require.NoError(t, err)
+	defer client.Close()
+
+	in := strings.NewReader(`{"flag": "new-checkout", "tenant": "acme"}
+{"flag": "new-checkout", "tenant": "other"}
+{"flag": "dark-mode"}
+`)
+	var out strings.Builder
+	summary, err := replay(client, in, "jsonl", &out)
+	require.NoError(t, err)
+
+	require.Equal(t, []replaySummary{
+		{Flag: "dark-mode", Enabled: 1, Total: 1},
+		{Flag: "new-checkout", Enabled: 1, Total: 2},
+	}, summary)
+	require.Equal(t, `{"flag":"new-checkout","tenant":"acme","enabled":true,"reason":"TENANT"}
+{"flag":"new-checkout","tenant":"other","enabled":false,"reason":"TENANT_NOT_FOUND"}
+{"flag":"dark-mode","enabled":true,"reason":"GLOBAL"}
+`, out.String())
+}
+
+func TestReplayCSV(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(replaySnapshot))
+	require.NoError(t, err)
+	defer client.Close()
+
+	in := strings.NewReader("flag,tenant,user\nnew-checkout,acme,u1\nnew-checkout\n")
+	summary, err := replay(client, in, "csv", new(strings.Builder))
+	require.NoError(t, err)
+	require.Equal(t, []replaySummary{{Flag: "new-checkout", Enabled: 1, Total: 2}}, summary)
+}
+
+func TestReplayAttributes(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(replaySnapshot))
+	require.NoError(t, err)
+	defer client.Close()
+
+	in := strings.NewReader(`{"flag": "pricing", "attributes": {"country": "ES"}}
+{"flag": "pricing", "attributes": {"country": "FR"}}
+`)
+	var out strings.Builder
+	summary, err := replay(client, in, "jsonl", &out)
+	require.NoError(t, err)
+	require.Equal(t, []replaySummary{{Flag: "pricing", Enabled: 1, Total: 2}}, summary)
+	require.Equal(t, `{"flag":"pricing","attributes":{"country":"ES"},"enabled":false,"reason":"ATTRIBUTE"}
+{"flag":"pricing","attributes":{"country":"FR"},"enabled":true,"reason":"GLOBAL"}
+`, out.String())
+}
+
+func TestReplayCSVAttributes(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(replaySnapshot))
+	require.NoError(t, err)
+	defer client.Close()
+
+	in := strings.NewReader("pricing,,u1,country=ES,plan=pro\npricing,,u2\n")
+	summary, err := replay(client, in, "csv", new(strings.Builder))
+	require.NoError(t, err)
+	require.Equal(t, []replaySummary{{Flag: "pricing", Enabled: 1, Total: 2}}, summary)
+
+	_, err = replay(client, strings.NewReader("pricing,,u1,country\n"), "csv", new(strings.Builder))
+	require.EqualError(t, err, `cannot read evaluation: invalid attribute "country", expected key=value`)
+}
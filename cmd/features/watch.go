@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+func runWatch(ctx context.Context, args []string) error {
+	var sf serverFlags
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	sf.register(fs)
+	interval := fs.Duration("interval", 5*time.Second, "Time between polls to the server.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := sf.validate(); err != nil {
+		return err
+	}
+
+	var last map[string]flagReply
+	for {
+		flags, err := fetchFlags(ctx, sf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			printChange("! %s", err)
+		} else {
+			current := make(map[string]flagReply, len(flags))
+			for _, f := range flags {
+				current[f.Code] = f
+			}
+
+			if last == nil {
+				printChange("watching %d flags of project %s", len(current), sf.project)
+			} else {
+				diffFlags(last, current)
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func diffFlags(before, after map[string]flagReply) {
+	for code, f := range after {
+		prev, ok := before[code]
+		if !ok {
+			printChange("+ %s added (enabled=%v)", code, f.Enabled)
+			continue
+		}
+
+		if prev.Enabled != f.Enabled {
+			printChange("~ %s enabled: %v -> %v", code, prev.Enabled, f.Enabled)
+		}
+		diffTenants(code, prev.Tenants, f.Tenants)
+	}
+
+	for code := range before {
+		if _, ok := after[code]; !ok {
+			printChange("- %s removed", code)
+		}
+	}
+}
+
+func diffTenants(code string, before, after []flagTenant) {
+	prev := make(map[string]bool, len(before))
+	for _, t := range before {
+		prev[t.Code] = t.Enabled
+	}
+
+	for _, t := range after {
+		enabled, ok := prev[t.Code]
+		switch {
+		case !ok:
+			printChange("+ %s[%s] added (enabled=%v)", code, t.Code, t.Enabled)
+		case enabled != t.Enabled:
+			printChange("~ %s[%s] enabled: %v -> %v", code, t.Code, enabled, t.Enabled)
+		}
+		delete(prev, t.Code)
+	}
+
+	for tenant := range prev {
+		printChange("- %s[%s] removed", code, tenant)
+	}
+}
+
+func printChange(format string, args ...any) {
+	fmt.Printf("%s  %s\n", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+}
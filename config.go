@@ -0,0 +1,126 @@
+package features
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// FlagConfig is the configuration of a flag received from the server.
+type FlagConfig struct {
+	Code    string
+	Enabled bool
+
+	// Tenants with a specific value. Empty for global flags.
+	Tenants []TenantConfig
+
+	// Users with a specific value, that takes precedence over their tenant.
+	Users []TenantConfig
+
+	// Targeting rules over the attributes of the evaluation, in order.
+	Rules []RuleConfig
+
+	// Tenants that have the flag disabled even if it is enabled for everyone else.
+	ExcludedTenants []string
+
+	// TTL of the cached flag configured in the server, if any.
+	TTL time.Duration
+
+	// Raw JSON value of the flag, if any.
+	Value json.RawMessage
+
+	// Archived flags evaluate to their global value for everyone.
+	Archived bool
+
+	// Killed flags were disabled in an emergency by the server.
+	Killed bool
+
+	// Terminal value of the flag if its rollout is complete.
+	Frozen *bool
+}
+
+// TenantConfig is the value of a flag for a tenant.
+type TenantConfig struct {
+	Code    string
+	Enabled bool
+}
+
+// RuleConfig is the value of a flag for the evaluations whose attribute has one of
+// the values.
+type RuleConfig struct {
+	Attribute string
+	Values    []string
+	Enabled   bool
+}
+
+func newFlagConfig(reply flagReply) FlagConfig {
+	config := FlagConfig{
+		Code:     reply.Code,
+		Enabled:  reply.Enabled,
+		TTL:      time.Duration(reply.TTL) * time.Second,
+		Value:    bytes.Clone(reply.Value),
+		Archived: reply.Archived,
+		Killed:   reply.Killed,
+
+		ExcludedTenants: slices.Clone(reply.ExcludedTenants),
+	}
+	if reply.Frozen != nil {
+		frozen := *reply.Frozen
+		config.Frozen = &frozen
+	}
+	for _, t := range reply.Tenants {
+		config.Tenants = append(config.Tenants, TenantConfig{Code: t.Code, Enabled: t.Enabled})
+	}
+	for _, u := range reply.Users {
+		config.Users = append(config.Users, TenantConfig{Code: u.Code, Enabled: u.Enabled})
+	}
+	for _, r := range reply.Rules {
+		config.Rules = append(config.Rules, RuleConfig{Attribute: r.Attribute, Values: slices.Clone(r.Values), Enabled: r.Enabled})
+	}
+	return config
+}
+
+// newFlagReply converts back a configuration that can be modified by the application.
+func newFlagReply(config FlagConfig, volatile bool) flagReply {
+	reply := flagReply{
+		Code:     config.Code,
+		Enabled:  config.Enabled,
+		TTL:      int64(config.TTL / time.Second),
+		Volatile: volatile,
+		Value:    bytes.Clone(config.Value),
+		Archived: config.Archived,
+		Killed:   config.Killed,
+
+		ExcludedTenants: slices.Clone(config.ExcludedTenants),
+	}
+	if config.Frozen != nil {
+		frozen := *config.Frozen
+		reply.Frozen = &frozen
+	}
+	for _, t := range config.Tenants {
+		reply.Tenants = append(reply.Tenants, flagTenant{Code: t.Code, Enabled: t.Enabled})
+	}
+	for _, u := range config.Users {
+		reply.Users = append(reply.Users, flagTenant{Code: u.Code, Enabled: u.Enabled})
+	}
+	for _, r := range config.Rules {
+		reply.Rules = append(reply.Rules, flagRule{Attribute: r.Attribute, Values: slices.Clone(r.Values), Enabled: r.Enabled})
+	}
+	return reply
+}
+
+// FlagConfig returns a copy of the cached configuration of the flag. It returns false
+// if the flag is not present in the last payload of the server. Overrides are not
+// applied, use Detail to know the effective value.
+func (c *Client) FlagConfig(code string) (FlagConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, f := range c.flags {
+		if f.Code == code {
+			return newFlagConfig(f), true
+		}
+	}
+	return FlagConfig{}, false
+}
@@ -0,0 +1,41 @@
+package features
+
+import (
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagConfig(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+		require.NoError(t, DefaultClient.WaitForReady(t.Context()))
+
+		config, ok := DefaultClient.FlagConfig("tenant-enabled")
+		require.True(t, ok)
+		require.Equal(t, "tenant-enabled", config.Code)
+		require.True(t, config.Enabled)
+		require.Contains(t, config.Tenants, TenantConfig{Code: "foo-tenant", Enabled: true})
+
+		// Modifying the copy does not change the cached flags.
+		config.Tenants[0].Enabled = !config.Tenants[0].Enabled
+		again, _ := DefaultClient.FlagConfig("tenant-enabled")
+		require.NotEqual(t, config.Tenants[0], again.Tenants[0])
+
+		_, ok = DefaultClient.FlagConfig("unknown")
+		require.False(t, ok)
+	})
+}
+
+func TestFlagConfigFrozen(t *testing.T) {
+	initFlags()
+	frozen := true
+	DefaultClient.flags = []flagReply{{Code: "frozen", Enabled: true, Frozen: &frozen}}
+
+	config, ok := DefaultClient.FlagConfig("frozen")
+	require.True(t, ok)
+	*config.Frozen = false
+	require.True(t, *DefaultClient.flags[0].Frozen)
+}
@@ -0,0 +1,140 @@
+// Package conformance verifies that a features server implements the contracts of
+// the /eval and /stats endpoints this client relies on. Alternative implementations
+// of the server can run it from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Test(t, "http://localhost:8080", "conformance")
+//	}
+//
+// The project should exist in the server, although it may not have any flags.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flagReply struct {
+	Code    *string      `json:"code"`
+	Enabled *bool        `json:"enabled"`
+	Tenants []flagTenant `json:"tenants"`
+}
+
+type flagTenant struct {
+	Code    *string `json:"code"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// Test runs the conformance tests against the server for the project.
+func Test(t *testing.T, serverURL, project string) {
+	evalURL := serverURL + "/eval?" + url.Values{"project": {project}}.Encode()
+	statsURL := serverURL + "/stats"
+
+	t.Run("eval", func(t *testing.T) {
+		resp, body := request(t, http.MethodGet, evalURL, nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /eval: status code %d, want 200", resp.StatusCode)
+		}
+
+		var flags []flagReply
+		if err := json.Unmarshal(body, &flags); err != nil {
+			t.Fatalf("GET /eval: body should be a JSON array of flags: %s", err)
+		}
+		for i, f := range flags {
+			if f.Code == nil || *f.Code == "" {
+				t.Errorf("GET /eval: flag %d without code", i)
+			}
+			if f.Enabled == nil {
+				t.Errorf("GET /eval: flag %d without enabled", i)
+			}
+			for j, tenant := range f.Tenants {
+				if tenant.Code == nil || *tenant.Code == "" || tenant.Enabled == nil {
+					t.Errorf("GET /eval: tenant %d of flag %d should have code and enabled", j, i)
+				}
+			}
+		}
+	})
+
+	t.Run("eval etag", func(t *testing.T) {
+		first, body := request(t, http.MethodGet, evalURL, nil, nil)
+		etag := first.Header.Get("ETag")
+		if etag == "" {
+			t.Skip("the server does not send ETags")
+		}
+		quoted := strings.HasPrefix(strings.TrimPrefix(etag, "W/"), `"`) && strings.HasSuffix(etag, `"`) && len(etag) > 1
+		if !quoted {
+			t.Errorf("GET /eval: ETag %s should be a quoted string", etag)
+		}
+
+		second, again := request(t, http.MethodGet, evalURL, nil, nil)
+		if bytes.Equal(body, again) && second.Header.Get("ETag") != etag {
+			t.Errorf("GET /eval: ETag changed from %s to %s with the same payload", etag, second.Header.Get("ETag"))
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		payload := fmt.Sprintf(`{"project":%q,"instanceId":"conformance","hostname":"conformance","stats":[{"bucket":%d,"flag":"conformance","enabledHits":1,"totalHits":2}]}`,
+			project, time.Now().Truncate(time.Minute).UnixMilli())
+		header := http.Header{
+			"Content-Type":    {"application/json"},
+			"Idempotency-Key": {fmt.Sprintf("conformance-%d", time.Now().UnixNano())},
+		}
+
+		// Retries with the same idempotency key should succeed too.
+		for range 2 {
+			resp, _ := request(t, http.MethodPost, statsURL, header, []byte(payload))
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+				t.Fatalf("POST /stats: status code %d, want 200 or 204", resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("stats empty", func(t *testing.T) {
+		payload := fmt.Sprintf(`{"project":%q,"instanceId":"conformance","stats":[]}`, project)
+		resp, _ := request(t, http.MethodPost, statsURL, http.Header{"Content-Type": {"application/json"}}, []byte(payload))
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("POST /stats: status code %d, want 200 or 204", resp.StatusCode)
+		}
+	})
+
+	t.Run("stats malformed", func(t *testing.T) {
+		resp, _ := request(t, http.MethodPost, statsURL, http.Header{"Content-Type": {"application/json"}}, []byte(`{"stats":`))
+		if resp.StatusCode < 400 || resp.StatusCode >= 500 {
+			t.Fatalf("POST /stats: status code %d for a malformed body, want 4xx", resp.StatusCode)
+		}
+	})
+}
+
+func request(t *testing.T, method, u string, header http.Header, body []byte) (*http.Response, []byte) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	reply, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: cannot read body: %s", method, req.URL.Path, err)
+	}
+	return resp, reply
+}
@@ -0,0 +1,34 @@
+package conformance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReferenceServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eval":
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(`[{"code":"foo","enabled":true,"tenants":[{"code":"acme","enabled":false}]}]`))
+
+		case "/stats":
+			var in struct {
+				Project string `json:"project"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	Test(t, server.URL, "conformance")
+}
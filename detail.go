@@ -0,0 +1,122 @@
+package features
+
+// Reason explains why a flag evaluated to its result.
+type Reason string
+
+const (
+	// ReasonUnconfigured means the default client was not configured and the
+	// result depends on the environment.
+	ReasonUnconfigured Reason = "UNCONFIGURED"
+
+	// ReasonLocal means all flags are enabled in the local environment.
+	ReasonLocal Reason = "LOCAL"
+
+	// ReasonNotFound means the flag does not exist in the server or the flags were
+	// not fetched yet. The result is the default of the flag.
+	ReasonNotFound Reason = "NOT_FOUND"
+
+	// ReasonGlobal means the flag has the same value for every tenant.
+	ReasonGlobal Reason = "GLOBAL"
+
+	// ReasonDisabled means the flag is disabled for every tenant.
+	ReasonDisabled Reason = "DISABLED"
+
+	// ReasonTenant means the tenant has a specific value configured in the flag.
+	ReasonTenant Reason = "TENANT"
+
+	// ReasonTenantNotFound means the flag is scoped to tenants but the requested
+	// one is not configured.
+	ReasonTenantNotFound Reason = "TENANT_NOT_FOUND"
+
+	// ReasonTenantMissing means the flag is scoped to tenants but it was evaluated
+	// without one. The result depends on the EmptyTenantPolicy of the client.
+	ReasonTenantMissing Reason = "TENANT_MISSING"
+
+	// ReasonTenantExcluded means the tenant is in the exclusion list of the flag.
+	ReasonTenantExcluded Reason = "TENANT_EXCLUDED"
+
+	// ReasonUser means the user has a specific value configured in the flag.
+	ReasonUser Reason = "USER"
+
+	// ReasonAttribute means a targeting rule of the flag matched an attribute of
+	// the evaluation, like the country or the plan.
+	ReasonAttribute Reason = "ATTRIBUTE"
+
+	// ReasonStale means the flags were not refreshed for longer than the maximum
+	// staleness configured in the client. The result is the default of the flag.
+	ReasonStale Reason = "STALE"
+
+	// ReasonStaleRevalidating means the flags were older than the stale duration
+	// and the result was evaluated with them while they are refreshed in the
+	// background, because they are still newer than the maximum staleness.
+	ReasonStaleRevalidating Reason = "STALE_REVALIDATING"
+
+	// ReasonOverride means the value was forced by an override layer.
+	ReasonOverride Reason = "OVERRIDE"
+
+	// ReasonArchived means the flag was archived in the server and evaluates to
+	// its terminal global value for every tenant and user.
+	ReasonArchived Reason = "ARCHIVED"
+
+	// ReasonFrozen means the rollout of the flag is complete and it is frozen at
+	// its terminal value for every tenant and user.
+	ReasonFrozen Reason = "FROZEN"
+
+	// ReasonKilled means the flag was disabled in an emergency by the server.
+	ReasonKilled Reason = "KILLED"
+)
+
+// Layer is the source of the value of a flag. Layers are applied in order, each one
+// overriding the previous ones: server, file, env and test.
+type Layer string
+
+const (
+	// LayerServer is the payload fetched from the server.
+	LayerServer Layer = "SERVER"
+
+	// LayerFile is the overrides file configured with WithOverridesFile.
+	LayerFile Layer = "FILE"
+
+	// LayerEnv is the FEATURES_OVERRIDES environment variable.
+	LayerEnv Layer = "ENV"
+
+	// LayerTest are the overrides set programmatically with Client.Override.
+	LayerTest Layer = "TEST"
+)
+
+// FlagDetail is the result of evaluating a flag.
+type FlagDetail struct {
+	Enabled bool
+	Reason  Reason
+
+	// Layer that set the value, or empty if no layer has it and the value is a default.
+	Layer Layer
+
+	// Trace of the rules checked in order until the one that decided the result. It
+	// is only filled for the flags of the server if the client was configured with
+	// WithEvaluationTrace.
+	Trace []TraceStep
+
+	// Duplicated is true if the payload contained the flag more than once and the
+	// last entry was used, see WithDuplicatePolicy.
+	Duplicated bool
+}
+
+// TraceStep is a rule of the flag checked during an evaluation.
+type TraceStep struct {
+	// Rule checked, named after the reason of the result when it matches.
+	Rule Reason
+
+	// Matched is true for the rule that decided the result.
+	Matched bool
+
+	// Note explains why the rule did not match.
+	Note string
+}
+
+func addStep(trace *[]TraceStep, rule Reason, matched bool, note string) {
+	if trace == nil {
+		return
+	}
+	*trace = append(*trace, TraceStep{Rule: rule, Matched: matched, Note: note})
+}
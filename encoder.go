@@ -0,0 +1,64 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// StatsEncoder serializes the batches of stats sent to the server. Servers that do
+// not accept the content type should reply 415 Unsupported Media Type, and the
+// client falls back to JSON.
+type StatsEncoder interface {
+	ContentType() string
+	Encode(w io.Writer, req StatsRequest) error
+}
+
+type jsonStatsEncoder struct{}
+
+func (jsonStatsEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (jsonStatsEncoder) Encode(w io.Writer, req StatsRequest) error {
+	return json.NewEncoder(w).Encode(req)
+}
+
+type ndjsonStatsEncoder struct{}
+
+// NDJSONStatsEncoder encodes the stats as newline delimited JSON. The first line
+// has every field of the request except the stats, and each following line is
+// a StatEntry, so the server can process big batches as a stream.
+func NDJSONStatsEncoder() StatsEncoder {
+	return ndjsonStatsEncoder{}
+}
+
+func (ndjsonStatsEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (ndjsonStatsEncoder) Encode(w io.Writer, req StatsRequest) error {
+	encoder := json.NewEncoder(w)
+	// The header shadows the stats of the request to send every other field of it.
+	header := struct {
+		StatsRequest
+		Stats []StatEntry `json:"stats,omitempty"`
+	}{StatsRequest: req}
+	if err := encoder.Encode(header); err != nil {
+		return fmt.Errorf("cannot encode header: %w", err)
+	}
+	for _, entry := range req.Stats {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("cannot encode stat: %w", err)
+		}
+	}
+	return nil
+}
+
+// statsEncoder returns the configured encoder, unless the server rejected it.
+func (c *Client) statsEncoder() StatsEncoder {
+	if c.encoder == nil || c.encoderRejected.Load() {
+		return jsonStatsEncoder{}
+	}
+	return c.encoder
+}
@@ -0,0 +1,48 @@
+package features
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONStatsEncoder(t *testing.T) {
+	var buf strings.Builder
+	err := NDJSONStatsEncoder().Encode(&buf, StatsRequest{
+		Project:    "foo-project",
+		InstanceID: "foo-instance",
+		Stats: []StatEntry{
+			{Bucket: 946684800000, Flag: "foo", EnabledHits: 1, TotalHits: 2},
+			{Bucket: 946684800000, Flag: "bar", TotalHits: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, `{"project":"foo-project","instanceId":"foo-instance"}
+{"bucket":946684800000,"flag":"foo","enabledHits":1,"totalHits":2}
+{"bucket":946684800000,"flag":"bar","enabledHits":0,"totalHits":3}
+`, buf.String())
+}
+
+func TestNDJSONStatsEncoderHeader(t *testing.T) {
+	var buf strings.Builder
+	err := NDJSONStatsEncoder().Encode(&buf, StatsRequest{
+		Project:    "foo-project",
+		InstanceID: "foo-instance",
+		Hostname:   "foo-host",
+		Region:     "europe-west1",
+		Service:    "foo-service",
+		Version:    "v1.2.3",
+		Revision:   "abc123",
+		Scope:      map[string]string{"cluster": "prod"},
+		Stats: []StatEntry{
+			{Bucket: 946684800000, Flag: "foo", TotalHits: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, `{"project":"foo-project","instanceId":"foo-instance","hostname":"foo-host","region":"europe-west1","service":"foo-service","version":"v1.2.3","revision":"abc123","scope":{"cluster":"prod"}}
+{"bucket":946684800000,"flag":"foo","enabledHits":0,"totalHits":1}
+`, buf.String())
+}
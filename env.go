@@ -0,0 +1,67 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ConfigureFromEnv configures the default client reading the environment variables:
+//
+//   - FEATURES_SERVER_URL: URL of the server. Required.
+//   - FEATURES_PROJECT: project of the flags. Required.
+//   - FEATURES_API_KEY: key to authenticate the requests.
+//   - FEATURES_SERVICE: name of the service that evaluates the flags.
+//   - FEATURES_DISABLE_STATS: "true" to stop sending stats.
+//   - FEATURES_STATS_URL: endpoint of the stats, if it is not the server.
+//   - FEATURES_STATS_API_KEY: key to authenticate the stats, if it is not the same.
+//   - FEATURES_OVERRIDES_FILE: path of a JSON file with overrides of the flags.
+//
+// The options passed as arguments take precedence over the environment.
+func ConfigureFromEnv(opts ...ConfigureOption) error {
+	serverURL, project, envOpts, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	Configure(serverURL, project, append(envOpts, opts...)...)
+	return nil
+}
+
+func configFromEnv() (string, string, []ConfigureOption, error) {
+	var errs []error
+	serverURL := os.Getenv("FEATURES_SERVER_URL")
+	if serverURL == "" {
+		errs = append(errs, errors.New("features: missing FEATURES_SERVER_URL environment variable"))
+	}
+	project := os.Getenv("FEATURES_PROJECT")
+	if project == "" {
+		errs = append(errs, errors.New("features: missing FEATURES_PROJECT environment variable"))
+	}
+
+	var opts []ConfigureOption
+	if key := os.Getenv("FEATURES_API_KEY"); key != "" {
+		opts = append(opts, WithAPIKey(key))
+	}
+	if service := os.Getenv("FEATURES_SERVICE"); service != "" {
+		opts = append(opts, WithService(service))
+	}
+	if value := os.Getenv("FEATURES_DISABLE_STATS"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("features: invalid FEATURES_DISABLE_STATS environment variable %q: %w", value, err))
+		}
+		opts = append(opts, WithDisableStats(disabled))
+	}
+	if statsURL := os.Getenv("FEATURES_STATS_URL"); statsURL != "" {
+		opts = append(opts, WithStatsURL(statsURL))
+	}
+	if key := os.Getenv("FEATURES_STATS_API_KEY"); key != "" {
+		opts = append(opts, WithStatsAuth(key))
+	}
+	if path := os.Getenv("FEATURES_OVERRIDES_FILE"); path != "" {
+		opts = append(opts, WithOverridesFile(path))
+	}
+
+	return serverURL, project, opts, errors.Join(errs...)
+}
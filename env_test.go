@@ -0,0 +1,47 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("FEATURES_SERVER_URL", "https://example.com")
+	t.Setenv("FEATURES_PROJECT", "foo-project")
+	t.Setenv("FEATURES_API_KEY", "secret")
+	t.Setenv("FEATURES_SERVICE", "api-gateway")
+	t.Setenv("FEATURES_DISABLE_STATS", "true")
+	t.Setenv("FEATURES_STATS_URL", "https://ingest.example.com/stats")
+	t.Setenv("FEATURES_STATS_API_KEY", "stats-secret")
+
+	serverURL, project, opts, err := configFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", serverURL)
+	require.Equal(t, "foo-project", project)
+
+	o := newConfigureOptions(opts)
+	require.Equal(t, "secret", o.apiKey)
+	require.Equal(t, "api-gateway", o.service)
+	require.True(t, o.disableStats)
+	require.Equal(t, "https://ingest.example.com/stats", o.statsURL)
+	require.Equal(t, "stats-secret", o.statsAPIKey)
+}
+
+func TestConfigFromEnvMissing(t *testing.T) {
+	t.Setenv("FEATURES_SERVER_URL", "")
+	t.Setenv("FEATURES_PROJECT", "")
+
+	err := ConfigureFromEnv()
+	require.ErrorContains(t, err, "FEATURES_SERVER_URL")
+	require.ErrorContains(t, err, "FEATURES_PROJECT")
+}
+
+func TestConfigFromEnvInvalid(t *testing.T) {
+	t.Setenv("FEATURES_SERVER_URL", "https://example.com")
+	t.Setenv("FEATURES_PROJECT", "foo-project")
+	t.Setenv("FEATURES_DISABLE_STATS", "foo")
+
+	err := ConfigureFromEnv()
+	require.ErrorContains(t, err, "FEATURES_DISABLE_STATS")
+}
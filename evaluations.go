@@ -0,0 +1,59 @@
+package features
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type evaluationListeners struct {
+	mu        sync.Mutex
+	next      int
+	listeners map[int]func(Event)
+
+	// Registered listeners copied for the evaluations to read them without locking.
+	// It is nil when there are no listeners, which keeps the hot path to a single load.
+	active atomic.Pointer[[]func(Event)]
+}
+
+// OnEvaluation registers fn to be called after each evaluation of a flag with the
+// same event the sink receives. It is called synchronously from the goroutine that
+// evaluates the flag, so it should return quickly. The returned function
+// unregisters the listener.
+func (c *Client) OnEvaluation(fn func(Event)) (stop func()) {
+	c.evaluations.mu.Lock()
+	defer c.evaluations.mu.Unlock()
+
+	if c.evaluations.listeners == nil {
+		c.evaluations.listeners = make(map[int]func(Event))
+	}
+	id := c.evaluations.next
+	c.evaluations.next++
+	c.evaluations.listeners[id] = fn
+	c.evaluations.publish()
+
+	return func() {
+		c.evaluations.mu.Lock()
+		defer c.evaluations.mu.Unlock()
+		delete(c.evaluations.listeners, id)
+		c.evaluations.publish()
+	}
+}
+
+func (l *evaluationListeners) publish() {
+	if len(l.listeners) == 0 {
+		l.active.Store(nil)
+		return
+	}
+	active := make([]func(Event), 0, len(l.listeners))
+	for _, fn := range l.listeners {
+		active = append(active, fn)
+	}
+	l.active.Store(&active)
+}
+
+func (l *evaluationListeners) load() []func(Event) {
+	if active := l.active.Load(); active != nil {
+		return *active
+	}
+	return nil
+}
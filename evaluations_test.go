@@ -0,0 +1,44 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnEvaluation(t *testing.T) {
+	initFlags()
+
+	var events []Event
+	stop := DefaultClient.OnEvaluation(func(event Event) {
+		events = append(events, event)
+	})
+
+	require.True(t, Flag("global-enabled"))
+	require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+
+	stop()
+	require.False(t, Flag("global-disabled"))
+
+	require.Len(t, events, 2)
+	require.Equal(t, "global-enabled", events[0].Flag)
+	require.True(t, events[0].Enabled)
+	require.Equal(t, ReasonGlobal, events[0].Reason)
+	require.Equal(t, "tenant-enabled", events[1].Flag)
+	require.Equal(t, "foo-tenant", events[1].Tenant)
+	require.Equal(t, ReasonTenant, events[1].Reason)
+}
+
+func TestOnEvaluationLocal(t *testing.T) {
+	DefaultClient = NewClient("https://example.com", "foo-project", WithLocal(true), WithDisableStats(true))
+
+	var events []Event
+	stop := DefaultClient.OnEvaluation(func(event Event) {
+		events = append(events, event)
+	})
+	defer stop()
+
+	require.True(t, Flag("foo"))
+	require.Len(t, events, 1)
+	require.Equal(t, ReasonLocal, events[0].Reason)
+}
@@ -0,0 +1,92 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+type exportedState struct {
+	Project   string                    `json:"project"`
+	FetchedAt time.Time                 `json:"fetchedAt"`
+	Flags     []flagReply               `json:"flags"`
+	Overrides map[Layer]map[string]bool `json:"overrides,omitempty"`
+}
+
+// Export serializes the effective state of the client, the flags fetched from the
+// server and the overrides, so it can be loaded later with NewStaticClient.
+func (c *Client) Export() ([]byte, error) {
+	state := exportedState{
+		Project:   c.project,
+		Overrides: make(map[Layer]map[string]bool),
+	}
+
+	c.mu.RLock()
+	state.FetchedAt = c.lastRefresh
+	state.Flags = c.flags
+	c.mu.RUnlock()
+
+	c.overridesMu.RLock()
+	for layer, overrides := range c.overrides {
+		if len(overrides) > 0 {
+			state.Overrides[layer] = maps.Clone(overrides)
+		}
+	}
+	c.overridesMu.RUnlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("features: cannot export state: %w", err)
+	}
+
+	return data, nil
+}
+
+// NewStaticClient creates a client from the state exported by Export. The client never
+// contacts the server nor sends stats, it always evaluates the exported flags. It is
+// meant for debugging tools and staging instances that reproduce the state of another one.
+// Only the options that change the evaluations apply to it: the logger, the error
+// reporter, fail open, the default tenant, the empty tenant policy and the trace.
+// The options that configure the server or the stats are ignored.
+func NewStaticClient(data []byte, opts ...ConfigureOption) (*Client, error) {
+	o := newConfigureOptions(opts)
+	if o.logger == nil {
+		o.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	var state exportedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("features: cannot import state: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		static:        true,
+		project:       state.Project,
+		logger:        o.logger,
+		reporter:      o.reporter,
+		failOpen:      o.failOpen,
+		defaultTenant: o.defaultTenant,
+		emptyTenants:  o.emptyTenantPolicy,
+		ctx:           ctx,
+		cancel:        cancel,
+		ready:         make(chan struct{}),
+		flags:         state.Flags,
+		lastRefresh:   state.FetchedAt,
+		overrides:     make(map[Layer]map[string]bool),
+		statsCh:       make(chan accessEvent),
+		rates:         newAccessRates(),
+		rules:         newRuleMemo(),
+		trace:         o.trace,
+	}
+	client.readyOnce.Do(func() { close(client.ready) })
+	for layer, overrides := range state.Overrides {
+		client.overrides[layer] = overrides
+	}
+
+	return client, nil
+}
@@ -0,0 +1,63 @@
+package features
+
+import (
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+
+		DefaultClient.Override("overridden", true)
+		require.True(t, Flag("global-enabled"))
+
+		data, err := DefaultClient.Export()
+		require.NoError(t, err)
+
+		static, err := NewStaticClient(data)
+		require.NoError(t, err)
+		defer static.Close()
+
+		require.True(t, static.Ready())
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, static.Detail("global-enabled", ""))
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonTenant, Layer: LayerServer}, static.Detail("tenant-enabled", "foo-tenant"))
+		require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonOverride, Layer: LayerTest}, static.Detail("overridden", ""))
+
+		// Many evaluations must not block waiting for a background goroutine.
+		for range 1000 {
+			static.IsEnabled("global-enabled", "")
+		}
+	})
+}
+
+func TestNewStaticClientOptions(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		data, err := DefaultClient.Export()
+		require.NoError(t, err)
+
+		static, err := NewStaticClient(data, WithFailOpen(true), WithDefaultTenant("foo-tenant"), WithEmptyTenantPolicy(EmptyTenantGlobal), WithEvaluationTrace(true))
+		require.NoError(t, err)
+		defer static.Close()
+
+		require.True(t, static.IsEnabled("unknown", ""))
+		require.Equal(t, "foo-tenant", static.defaultTenant)
+
+		detail := static.Detail("tenant-enabled", "")
+		require.True(t, detail.Enabled)
+		require.Equal(t, ReasonTenantMissing, detail.Reason)
+		require.NotEmpty(t, detail.Trace)
+	})
+}
+
+func TestNewStaticClientInvalid(t *testing.T) {
+	_, err := NewStaticClient([]byte("foo"))
+	require.Error(t, err)
+}
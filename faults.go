@@ -0,0 +1,87 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrInjectedFault is returned by the requests that fail because of the fault
+// injection configured in the client.
+var ErrInjectedFault = errors.New("features: injected fault")
+
+// FaultInjection configures the faults injected in the client to verify that the
+// services degrade gracefully when the flags misbehave. Rates are probabilities
+// between 0 and 1 applied to each request.
+type FaultInjection struct {
+	// FetchErrorRate fails the fetches of the flags without contacting the server.
+	FetchErrorRate float64
+
+	// StatsErrorRate fails the sends of the stats without contacting the server.
+	StatsErrorRate float64
+
+	// DelayRate delays the fetches and the sends of the stats by Delay before
+	// sending them.
+	DelayRate float64
+	Delay     time.Duration
+
+	// Stale stops refreshing the flags and serves the cached ones with the STALE
+	// reason, as if the server was unreachable for longer than the maximum staleness.
+	Stale bool
+}
+
+type faultTarget string
+
+const (
+	faultFetch faultTarget = "fetch"
+	faultStats faultTarget = "stats"
+)
+
+// InjectFaults replaces the faults injected in the client. Pass the zero value to
+// disable them.
+func (c *Client) InjectFaults(faults FaultInjection) {
+	if faults == (FaultInjection{}) {
+		c.faults.Store(nil)
+		return
+	}
+	c.faults.Store(&faults)
+}
+
+// injectFault returns an error if the request should fail, after delaying it if
+// configured.
+func (c *Client) injectFault(ctx context.Context, target faultTarget) error {
+	faults := c.faults.Load()
+	if faults == nil {
+		return nil
+	}
+
+	if faults.Delay > 0 && rand.Float64() < faults.DelayRate {
+		c.logger.Debug("feature flags: injecting delay", slog.String("target", string(target)), slog.Duration("delay", faults.Delay))
+		select {
+		case <-time.After(faults.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	rate := faults.StatsErrorRate
+	if target == faultFetch {
+		if faults.Stale {
+			return ErrInjectedFault
+		}
+		rate = faults.FetchErrorRate
+	}
+	if rand.Float64() < rate {
+		c.logger.Debug("feature flags: injecting error", slog.String("target", string(target)))
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// injectedStale returns true if the client should serve the cached flags as stale.
+func (c *Client) injectedStale() bool {
+	faults := c.faults.Load()
+	return faults != nil && faults.Stale
+}
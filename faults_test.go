@@ -0,0 +1,69 @@
+package features
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultFetchError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		DefaultClient.InjectFaults(FaultInjection{FetchErrorRate: 1})
+		require.False(t, Flag("global-enabled"))
+		require.Zero(t, tr.getRequests())
+
+		DefaultClient.InjectFaults(FaultInjection{})
+		time.Sleep(6 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+		require.NotZero(t, tr.getRequests())
+	})
+}
+
+func TestFaultDelay(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+
+		DefaultClient.InjectFaults(FaultInjection{DelayRate: 1, Delay: 2 * time.Second})
+		start := time.Now()
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 2*time.Second, time.Since(start))
+	})
+}
+
+func TestFaultStale(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+
+		DefaultClient.InjectFaults(FaultInjection{Stale: true})
+		detail := Detail("global-enabled")
+		require.True(t, detail.Enabled)
+		require.Equal(t, ReasonStale, detail.Reason)
+
+		DefaultClient.InjectFaults(FaultInjection{})
+		require.Equal(t, ReasonGlobal, Detail("global-enabled").Reason)
+	})
+}
+
+func TestFaultStatsError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		DefaultClient.InjectFaults(FaultInjection{StatsErrorRate: 1})
+		require.True(t, Flag("global-enabled"))
+		synctest.Wait()
+
+		err := DefaultClient.Close()
+		require.ErrorIs(t, err, ErrInjectedFault)
+		require.Nil(t, tr.last)
+	})
+}
@@ -1,65 +1,646 @@
 package features
 
 import (
-	"log/slog"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/altipla-consulting/env"
 )
 
-var DefaultClient *featuresClient
+var DefaultClient *Client
+
+// configureMu serializes the configuration of the default client.
+var configureMu sync.Mutex
+
+// ErrIncompatibleConfig is returned by ConfigureShared when the default client was
+// already configured with a different server or project.
+var ErrIncompatibleConfig = errors.New("features: incompatible configuration")
 
 // Initializes the feature client with the provided server URL and project,
 // and starts a background synchronization process.
+//
+// If it was already configured the previous client is closed. When both use the same
+// server and project, the cached flags and the pending stats are moved to the new
+// client, so there is no window without flags and no stats are lost.
 func Configure(serverURL, project string, opts ...ConfigureOption) {
-	o := new(configureOptions)
-	for _, opt := range opts {
-		opt(o)
+	configureMu.Lock()
+	defer configureMu.Unlock()
+
+	client := NewClient(serverURL, project, opts...)
+	if DefaultClient != nil {
+		client.handoff(DefaultClient)
 	}
-	DefaultClient = newClient(serverURL, project, o)
+	DefaultClient = client
+}
+
+// ConfigureShared initializes the default client only if it was not configured
+// before. Later calls with the same server and project keep the existing client and
+// ignore their options, while calls with a different server or project return
+// ErrIncompatibleConfig. Libraries can call it defensively without replacing the
+// client of the application.
+func ConfigureShared(serverURL, project string, opts ...ConfigureOption) error {
+	configureMu.Lock()
+	defer configureMu.Unlock()
+
+	if DefaultClient == nil {
+		DefaultClient = NewClient(serverURL, project, opts...)
+		return nil
+	}
+
+	server, _ := parseUnixURL(serverURL)
+	o := newConfigureOptions(opts)
+	if evalURL := buildEvalURL(server, project, o.service, o.scope); evalURL != DefaultClient.evalURL {
+		return fmt.Errorf("%w: configured with %s, requested %s", ErrIncompatibleConfig, DefaultClient.evalURL, evalURL)
+	}
+	return nil
 }
 
 type ConfigureOption func(*configureOptions)
 
 type configureOptions struct {
-	logger       *slog.Logger
-	disableStats bool
+	logger         Logger
+	reporter       ErrorReporter
+	disableStats   bool
+	disableFetch   bool
+	statsEnabled   func() bool
+	local          bool
+	statsRetention time.Duration
+	hostname       string
+	region         string
+	service        string
+	scope          map[string]string
+	noMetadata     bool
+
+	initialFetchTimeout time.Duration
+	failOpen            bool
+	flagTTLs            map[string]time.Duration
+	overrideSources     []overrideSource
+	overridesFile       string
+	flaps               *flapOptions
+	apiKey              string
+	idToken             *idTokenSource
+	dialContext         DialContextFunc
+	keepAlive           time.Duration
+	maxIdleConns        int
+	http2               *bool
+	hedgeDelay          time.Duration
+	statsMirror         string
+	sink                Sink
+	defaultTenant       string
+	fetchBudget         time.Duration
+	staleDuration       time.Duration
+	maxStaleness        time.Duration
+	criticalStaleness   time.Duration
+	pushgatewayURL      string
+	pushgatewayJob      string
+	serverClock         bool
+	goroutineLabels     bool
+	statsEncoder        StatsEncoder
+	faults              *FaultInjection
+	trace               bool
+	statsURL            string
+	statsAPIKey         string
+	duplicatePolicy     DuplicatePolicy
+	transform           func([]FlagConfig) []FlagConfig
+	emptyTenantPolicy   EmptyTenantPolicy
+}
+
+type overrideSource struct {
+	serverURL string
+	project   string
 }
 
-func WithLogger(logger *slog.Logger) ConfigureOption {
+func newConfigureOptions(opts []ConfigureOption) *configureOptions {
+	o := &configureOptions{
+		local:          env.IsLocal(),
+		statsRetention: 20 * time.Hour,
+	}
+	o.hostname, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLogger configures the logger of the client. It accepts a *slog.Logger directly,
+// or other loggers through adapters like FromZap and FromLogrus.
+func WithLogger(logger Logger) ConfigureOption {
 	return func(c *configureOptions) {
 		c.logger = logger
 	}
 }
 
+// WithErrorReporter sends the failures fetching flags or sending stats to the
+// reporter, besides logging them.
+func WithErrorReporter(reporter ErrorReporter) ConfigureOption {
+	return func(c *configureOptions) {
+		c.reporter = reporter
+	}
+}
+
 func WithDisableStats(disabled bool) ConfigureOption {
 	return func(c *configureOptions) {
 		c.disableStats = disabled
 	}
 }
 
+// WithDisableFetch stops fetching the flags from the server while the stats are
+// still sent. Flags evaluate with the override layers, like the overrides file, or
+// their defaults. Services that still have the values of the flags in their own
+// configuration can report their usage before migrating.
+func WithDisableFetch(disabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.disableFetch = disabled
+	}
+}
+
+// WithStatsEnabledFunc checks fn before each flush of the stats, that are discarded
+// instead of sent if it returns false. It allows toggling the stats without
+// restarting the process, for example with another flag or an environment switch.
+func WithStatsEnabledFunc(fn func() bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsEnabled = fn
+	}
+}
+
+// WithStatsRetention configures how long the stats are kept in memory when they
+// cannot be sent to the server. Older stats are discarded. By default it is 20 hours.
+func WithStatsRetention(d time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsRetention = d
+	}
+}
+
+// WithStatsEncoder changes the encoding of the stats sent to the server. By default
+// they are sent as JSON.
+func WithStatsEncoder(encoder StatsEncoder) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsEncoder = encoder
+	}
+}
+
+// WithStatsMirror appends each batch of stats to a local file as JSON lines, besides
+// sending it to the server. Each line has the idempotency key of the batch, so the
+// file can be used to audit or backfill the stats the server did not receive.
+func WithStatsMirror(path string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsMirror = path
+	}
+}
+
+// WithSink sends every evaluation of the flags to the sink, besides the aggregated
+// stats. Events are dropped if the sink cannot keep up with the evaluations.
+func WithSink(sink Sink) ConfigureOption {
+	return func(c *configureOptions) {
+		c.sink = sink
+	}
+}
+
+// WithPushgateway pushes the metrics of the fetches and evaluations of the client to a
+// Prometheus Pushgateway when it is closed. It is meant for batch jobs that live too
+// little to be scraped. The instance label is the hostname of the machine.
+func WithPushgateway(gatewayURL, job string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.pushgatewayURL = gatewayURL
+		c.pushgatewayJob = job
+	}
+}
+
+// WithServerClock assigns the stats to the minute buckets with the clock of the server,
+// estimated from the Date header of the last fetch, instead of the local clock.
+func WithServerClock(enabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.serverClock = enabled
+	}
+}
+
+// WithGoroutineLabels adds pprof labels to the background goroutines of the client
+// with component=features, the project and the name of the goroutine, so the
+// profiles and leak detectors attribute them. They are disabled by default because
+// the goroutines of the connections opened by the client inherit them too, and
+// the default HTTP client shares those connections with the rest of the process.
+func WithGoroutineLabels(enabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.goroutineLabels = enabled
+	}
+}
+
+// WithHostname overrides the hostname of the instance reported with the stats. By
+// default it is the hostname of the machine, which is the pod name in Kubernetes.
+func WithHostname(hostname string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.hostname = hostname
+	}
+}
+
+// WithRegion reports the region of the instance with the stats.
+func WithRegion(region string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.region = region
+	}
+}
+
+// WithService identifies the service that evaluates the flags. It is sent to the
+// server when fetching the flags, so it can target them to specific services, and
+// with the stats to attribute the usage of the flags to each service.
+func WithService(name string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.service = name
+	}
+}
+
+// WithScope identifies the infrastructure of the instance, like the cluster, the
+// region or the service, with labels that are sent to the server when fetching the
+// flags and with the stats. The server can use them to target the flags to a scope
+// instead of a tenant, like rolling out a new cache cluster by cluster.
+func WithScope(labels map[string]string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.scope = labels
+	}
+}
+
+// WithDisableInstanceMetadata stops reporting the hostname and region of the
+// instance with the stats.
+func WithDisableInstanceMetadata(disabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.noMetadata = disabled
+	}
+}
+
+// WithBlockingInitialFetch waits for the first fetch of the flags when creating the
+// client, up to the timeout. Otherwise evaluations will return false until the
+// flags are fetched.
+func WithBlockingInitialFetch(timeout time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.initialFetchTimeout = timeout
+	}
+}
+
+// WithFailOpen enables the flags that are unknown, either because the server does
+// not have them or because they were not fetched yet. By default they are disabled.
+// Flags with a default configured with Define ignore this option.
+func WithFailOpen(failOpen bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.failOpen = failOpen
+	}
+}
+
+// WithDuplicatePolicy configures how the payloads that contain the same flag more
+// than once are handled. By default they are rejected keeping the previous flags.
+func WithDuplicatePolicy(policy DuplicatePolicy) ConfigureOption {
+	return func(c *configureOptions) {
+		c.duplicatePolicy = policy
+	}
+}
+
+// WithPayloadTransform modifies the flags after each fetch, to filter, rename or
+// inject flags without changing the server. The function receives a copy of the
+// flags. The result must not contain duplicated flags, or the payload is rejected
+// keeping the previous flags.
+func WithPayloadTransform(transform func([]FlagConfig) []FlagConfig) ConfigureOption {
+	return func(c *configureOptions) {
+		c.transform = transform
+	}
+}
+
+// WithEmptyTenantPolicy configures the value of the tenant-scoped flags evaluated
+// without a tenant. By default they are disabled. Their reason is always
+// ReasonTenantMissing.
+func WithEmptyTenantPolicy(policy EmptyTenantPolicy) ConfigureOption {
+	return func(c *configureOptions) {
+		c.emptyTenantPolicy = policy
+	}
+}
+
+// EmptyTenantPolicy decides the value of the tenant-scoped flags evaluated without a
+// tenant, usually because the caller forgot WithTenant.
+type EmptyTenantPolicy int
+
+const (
+	// EmptyTenantDisabled evaluates them as disabled. It is the default.
+	EmptyTenantDisabled EmptyTenantPolicy = iota
+
+	// EmptyTenantGlobal evaluates them with the global value of the flag, ignoring
+	// the tenants configured in it.
+	EmptyTenantGlobal
+
+	// EmptyTenantWarn evaluates them as disabled and logs a warning the first time
+	// each flag is evaluated without a tenant.
+	EmptyTenantWarn
+)
+
+// WithFlagTTL marks the flag as critical with a maximum staleness shorter than the
+// rest. Accessing it refreshes the cache as soon as it is older than the TTL. The
+// server can also configure it for each flag.
+func WithFlagTTL(code string, ttl time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		if c.flagTTLs == nil {
+			c.flagTTLs = make(map[string]time.Duration)
+		}
+		c.flagTTLs[code] = ttl
+	}
+}
+
+// WithOverrideSource fetches the flags of an additional server and project that take
+// precedence over the main one. Flags with the same code replace entirely the ones
+// of the previous sources, so a company-wide server can enforce kill switches over
+// the flags of a team. It can be repeated; later sources have higher precedence.
+func WithOverrideSource(serverURL, project string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.overrideSources = append(c.overrideSources, overrideSource{serverURL, project})
+	}
+}
+
+// WithOverridesFile reads overrides of the flags from a JSON file with the flag codes
+// as keys, or "flag@tenant" for a specific tenant:
+//
+//	{"new-checkout": true, "new-header@acme": false}
+//
+// Overrides of the file have precedence over the server but not over the
+// FEATURES_OVERRIDES environment variable, that uses the format
+// "new-checkout=true,new-header@acme=false".
+func WithOverridesFile(path string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.overridesFile = path
+	}
+}
+
+// WithFlapDetection calls fn when the value of a flag changes more than threshold
+// times inside the window, which usually means a misconfiguration of the server or
+// automations fighting each other. Only the changes of the values that the flag
+// resolves to for any tenant, user or attribute count as a change of the flag.
+func WithFlapDetection(threshold int, window time.Duration, fn func(flag string, changes int)) ConfigureOption {
+	return func(c *configureOptions) {
+		c.flaps = &flapOptions{
+			threshold: threshold,
+			window:    window,
+			fn:        fn,
+		}
+	}
+}
+
+// WithAPIKey authenticates the requests to the server with the key as a bearer token.
+func WithAPIKey(key string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.apiKey = key
+	}
+}
+
+// WithStatsURL sends the stats to a different endpoint than the server, like a
+// separate ingestion service. It is the full URL of the endpoint, including the path.
+func WithStatsURL(statsURL string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsURL = statsURL
+	}
+}
+
+// WithStatsAuth authenticates the requests of the stats with the key as a bearer
+// token, instead of the credentials of the server.
+func WithStatsAuth(key string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.statsAPIKey = key
+	}
+}
+
+// WithGoogleIDToken authenticates the requests to the server with Google ID tokens for
+// the audience, minted by the metadata server of the instance. It is needed when the
+// server is a Cloud Run service that requires authenticated invocations. The tokens
+// are cached and refreshed before they expire.
+func WithGoogleIDToken(audience string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.idToken = newIDTokenSource(audience)
+	}
+}
+
+// DialContextFunc opens the connections to the server.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithDialContext opens the connections to the server with a custom dialer, for
+// example to reach a local sidecar. Server URLs like "unix:///run/features.sock" use a
+// unix domain socket without configuring the dialer.
+func WithDialContext(dial DialContextFunc) ConfigureOption {
+	return func(c *configureOptions) {
+		c.dialContext = dial
+	}
+}
+
+// WithKeepAlive configures how long the idle connections to the server are kept open
+// to be reused, and the period of the TCP keep-alives. A negative value disables them.
+func WithKeepAlive(d time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.keepAlive = d
+	}
+}
+
+// WithMaxIdleConns configures the maximum number of idle connections to the server
+// kept open. The default of the standard library only keeps 2 for each host.
+func WithMaxIdleConns(n int) ConfigureOption {
+	return func(c *configureOptions) {
+		c.maxIdleConns = n
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 in the connections to the server.
+func WithHTTP2(enabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.http2 = &enabled
+	}
+}
+
+// WithFetchHedging sends a second request to fetch the flags if the first one does not
+// respond before the delay, and uses the first response that arrives. The delay is
+// usually the p99 latency of the server.
+func WithFetchHedging(delay time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.hedgeDelay = delay
+	}
+}
+
+// WithDefaultTenant evaluates the flags for the tenant when the calls do not use
+// WithTenant, for services that always work with the same tenant.
+func WithDefaultTenant(tenant string) ConfigureOption {
+	return func(c *configureOptions) {
+		c.defaultTenant = tenant
+	}
+}
+
+// WithFetchBudget limits the latency that a fetch adds to an evaluation when the
+// cached flags are stale. After the budget the evaluation uses the stale flags, or
+// the defaults if there are none, while the fetch continues in the background.
+func WithFetchBudget(budget time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.fetchBudget = budget
+	}
+}
+
+// WithStaleDuration configures how long the fetched flags are fresh. Evaluations
+// after it fetch them again, or refresh them in the background if WithMaxStaleness
+// is configured. By default it is one minute.
+func WithStaleDuration(d time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.staleDuration = d
+	}
+}
+
+// WithMaxStaleness serves the stale flags while they are refreshed in the background,
+// up to the maximum staleness. Older flags are not used anymore and the evaluations
+// return the defaults with ReasonStale until a fetch succeeds.
+func WithMaxStaleness(d time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.maxStaleness = d
+	}
+}
+
+// WithCriticalStaleness sets the age of the flags after which ReadyHandler reports
+// the client as not ready. It defaults to the maximum staleness of WithMaxStaleness,
+// when the evaluations stop using the flags, or 30 minutes if it is not configured.
+func WithCriticalStaleness(d time.Duration) ConfigureOption {
+	return func(c *configureOptions) {
+		c.criticalStaleness = d
+	}
+}
+
+// WithFaultInjection injects faults in the fetches of the flags and the sends of the
+// stats to test how the service degrades. It should only be enabled explicitly in
+// testing environments. The faults can be changed later with Client.InjectFaults.
+func WithFaultInjection(faults FaultInjection) ConfigureOption {
+	return func(c *configureOptions) {
+		c.faults = &faults
+	}
+}
+
+// WithEvaluationTrace fills the trace of the rules checked in the detail of each
+// evaluation, to answer why a flag has a value for a tenant or user. It adds
+// allocations to every evaluation, so it is meant for debugging.
+func WithEvaluationTrace(enabled bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.trace = enabled
+	}
+}
+
+// WithLocal overrides the detection of the local environment, where all flags are
+// enabled without contacting the server. Mostly useful for tools that should always
+// evaluate the real flags.
+func WithLocal(local bool) ConfigureOption {
+	return func(c *configureOptions) {
+		c.local = local
+	}
+}
+
 type FlagOption func(*flagOptions)
 
 type flagOptions struct {
-	tenant string
+	tenant     string
+	user       string
+	attributes map[string]string
+	ctx        context.Context
+}
+
+func newFlagOptions(opts []FlagOption) *flagOptions {
+	o := new(flagOptions)
+	if DefaultClient != nil {
+		o.tenant = DefaultClient.defaultTenant
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
-// WithTenant sets the tenant for the flag.
+// WithTenant sets the tenant for the flag. It replaces the default tenant of the
+// client, if any.
 func WithTenant(tenant string) FlagOption {
 	return func(o *flagOptions) {
 		o.tenant = tenant
 	}
 }
 
+// WithUser sets the user for the flag, a separate dimension from the tenant. Users
+// configured in the flag have precedence over the value of their tenant.
+func WithUser(user string) FlagOption {
+	return func(o *flagOptions) {
+		o.user = user
+	}
+}
+
+// WithAttribute sets an attribute of the evaluation, like the country, the plan or
+// the version of the app, for the targeting rules of the flag. It can be repeated to
+// set multiple attributes.
+func WithAttribute(key, value string) FlagOption {
+	return func(o *flagOptions) {
+		if o.attributes == nil {
+			o.attributes = make(map[string]string)
+		}
+		o.attributes[key] = value
+	}
+}
+
+// WithContext evaluates the flag inside the context. If the context was prepared
+// with TrackEvaluations the result will be recorded in it. If the context has a
+// Snapshot of the same tenant the flag is evaluated in it.
+func WithContext(ctx context.Context) FlagOption {
+	return func(o *flagOptions) {
+		o.ctx = ctx
+	}
+}
+
 // Flag returns true if the flag is enabled with the given options.
 func Flag(code string, opts ...FlagOption) bool {
-	// Uninitialized client is considered as a basic development flag.
+	return Detail(code, opts...).Enabled
+}
+
+// Enabled returns true if the flag is enabled for the tenant. It is the same as Flag
+// with WithTenant, without building the options, for the hottest loops. An empty
+// tenant uses the default tenant of the client.
+func Enabled(code, tenant string) bool {
 	if DefaultClient == nil {
 		return env.IsLocal()
 	}
+	return DefaultClient.IsEnabled(code, cmp.Or(tenant, DefaultClient.defaultTenant))
+}
 
-	o := new(flagOptions)
-	for _, opt := range opts {
-		opt(o)
+// Detail evaluates the flag with the given options and returns the result with the
+// reason that explains it.
+func Detail(code string, opts ...FlagOption) FlagDetail {
+	detail, _ := detailFlags(code, newFlagOptions(opts))
+	return detail
+}
+
+// detailFlags evaluates the flag like Detail and returns the flags used in the
+// evaluation, so the value of the flag can be read from the same payload.
+func detailFlags(code string, o *flagOptions) (FlagDetail, []flagReply) {
+	// Uninitialized client is considered as a basic development flag.
+	detail := FlagDetail{Enabled: env.IsLocal(), Reason: ReasonUnconfigured}
+	var flags []flagReply
+	if snap := contextSnapshot(o); snap != nil {
+		detail, flags = snap.Detail(code), snap.flags
+	} else if memo := contextMemo(o); memo != nil && DefaultClient != nil {
+		detail, flags = memo.detail(code, o.tenant, o.user, o.attributes, func() (FlagDetail, []flagReply) {
+			return DefaultClient.detailFlags(code, o.tenant, o.user, o.attributes)
+		})
+	} else if DefaultClient != nil {
+		detail, flags = DefaultClient.detailFlags(code, o.tenant, o.user, o.attributes)
+	}
+
+	if o.ctx != nil {
+		recordEvaluation(o.ctx, code, o.tenant, detail.Enabled)
+	}
+
+	return detail, flags
+}
+
+func contextSnapshot(o *flagOptions) *Snapshot {
+	if o.ctx == nil {
+		return nil
+	}
+	if snap := FromContext(o.ctx); snap != nil && snap.tenant == o.tenant && o.user == "" && len(o.attributes) == 0 {
+		return snap
 	}
-	return DefaultClient.IsEnabled(code, o.tenant)
+	return nil
 }
@@ -0,0 +1,23 @@
+// Package featuresecho adapts the features middleware to Echo, in its own module so
+// the main package does not depend on Echo:
+//
+//	e := echo.New()
+//	e.Use(featuresecho.Middleware(func(r *http.Request) string {
+//		return r.Header.Get("X-Tenant")
+//	}))
+//
+// The handlers retrieve the snapshot from the context of the request with
+// features.FromContext(c.Request().Context()).
+package featuresecho
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+// Middleware stores a Snapshot of the flags in the context of each request. See
+// features.Middleware for the details and the options.
+func Middleware(tenant features.TenantFunc, opts ...features.MiddlewareOption) echo.MiddlewareFunc {
+	return echo.WrapMiddleware(features.Middleware(tenant, opts...))
+}
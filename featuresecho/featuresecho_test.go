@@ -0,0 +1,66 @@
+package featuresecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+func initClient(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(`{
+		"project": "foo",
+		"flags": [
+			{"code": "new-checkout", "enabled": true, "tenants": [{"code": "acme", "enabled": true}]},
+			{"code": "dark-mode", "enabled": false}
+		]
+	}`))
+	require.NoError(t, err)
+	features.DefaultClient = client
+	t.Cleanup(func() {
+		client.Close()
+		features.DefaultClient = nil
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	initClient(t)
+
+	e := echo.New()
+	e.Use(Middleware(func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	}, features.WithEvaluatedHeader("new-checkout", "dark-mode")))
+	e.GET("/", func(c echo.Context) error {
+		snap := features.FromContext(c.Request().Context())
+		return c.String(http.StatusCreated, strconv.FormatBool(snap.Flag("new-checkout"))+" "+strconv.FormatBool(snap.Flag("dark-mode")))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "true false", w.Body.String())
+	require.Equal(t, "new-checkout=true,dark-mode=false", w.Header().Get("X-Features-Evaluated"))
+}
+
+func TestMiddlewareError(t *testing.T) {
+	initClient(t)
+
+	e := echo.New()
+	e.Use(Middleware(nil))
+	e.GET("/", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusForbidden)
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
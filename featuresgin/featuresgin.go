@@ -0,0 +1,57 @@
+// Package featuresgin adapts the features middleware to Gin, in its own module so
+// the main package does not depend on Gin:
+//
+//	r := gin.New()
+//	r.Use(featuresgin.Middleware(func(r *http.Request) string {
+//		return r.Header.Get("X-Tenant")
+//	}))
+//
+// The handlers retrieve the snapshot from the context of the request with
+// features.FromContext(c.Request.Context()).
+package featuresgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+// Middleware stores a Snapshot of the flags in the context of each request. See
+// features.Middleware for the details and the options.
+func Middleware(tenant features.TenantFunc, opts ...features.MiddlewareOption) gin.HandlerFunc {
+	mw := features.Middleware(tenant, opts...)
+	return func(c *gin.Context) {
+		writer := c.Writer
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Request = r
+			c.Writer = &responseWriter{ResponseWriter: writer, w: w}
+			c.Next()
+		})).ServeHTTP(writer, c.Request)
+		c.Writer = writer
+	}
+}
+
+// responseWriter sends the response of the handlers through the writer of the
+// middleware, so it can add its headers before they are sent.
+type responseWriter struct {
+	gin.ResponseWriter
+	w http.ResponseWriter
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	return rw.w.Write(b)
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.w.Write([]byte(s))
+}
+
+func (rw *responseWriter) Flush() {
+	http.NewResponseController(rw.w).Flush()
+}
@@ -0,0 +1,68 @@
+package featuresgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+func initClient(t *testing.T) {
+	client, err := features.NewStaticClient([]byte(`{
+		"project": "foo",
+		"flags": [
+			{"code": "new-checkout", "enabled": true, "tenants": [{"code": "acme", "enabled": true}]},
+			{"code": "dark-mode", "enabled": false}
+		]
+	}`))
+	require.NoError(t, err)
+	features.DefaultClient = client
+	t.Cleanup(func() {
+		client.Close()
+		features.DefaultClient = nil
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	initClient(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware(func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	}, features.WithEvaluatedHeader("new-checkout", "dark-mode")))
+	r.GET("/", func(c *gin.Context) {
+		snap := features.FromContext(c.Request.Context())
+		c.String(http.StatusCreated, "%v %v", snap.Flag("new-checkout"), snap.Flag("dark-mode"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "true false", w.Body.String())
+	require.Equal(t, "new-checkout=true,dark-mode=false", w.Header().Get("X-Features-Evaluated"))
+}
+
+func TestMiddlewareEmptyResponse(t *testing.T) {
+	initClient(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware(nil, features.WithEvaluatedHeader("dark-mode")))
+	r.GET("/", func(c *gin.Context) {
+		features.FromContext(c.Request.Context()).Flag("dark-mode")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "dark-mode=false", w.Header().Get("X-Features-Evaluated"))
+}
@@ -0,0 +1,90 @@
+// Package featurestest provides assertions over the flags evaluated during a test,
+// so integration tests can check a code path actually consulted the expected flag
+// instead of inferring it from its behavior:
+//
+//	func TestCheckout(t *testing.T) {
+//		featurestest.Record(t)
+//		// ... exercise the checkout ...
+//		featurestest.AssertEvaluated(t, "new-checkout", true)
+//	}
+//
+// The evaluations are recorded from the default client, that should be configured
+// before calling Record.
+package featurestest
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[testing.TB]*features.Recorder)
+)
+
+// Record starts recording the evaluations of the default client until the end of
+// the test.
+func Record(t testing.TB) {
+	t.Helper()
+
+	if features.DefaultClient == nil {
+		t.Fatal("featurestest: configure the features client before recording the evaluations")
+	}
+
+	r := features.NewRecorder(features.DefaultClient, 0)
+
+	recordersMu.Lock()
+	recorders[t] = r
+	recordersMu.Unlock()
+
+	t.Cleanup(func() {
+		r.Close()
+		recordersMu.Lock()
+		delete(recorders, t)
+		recordersMu.Unlock()
+	})
+}
+
+func recorderFor(t testing.TB) *features.Recorder {
+	t.Helper()
+
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[t]
+	if !ok {
+		t.Fatal("featurestest: call Record before asserting the evaluations")
+	}
+	return r
+}
+
+// AssertEvaluated fails the test if the flag was not evaluated with the expected
+// result since Record was called. Other evaluations of the same flag with a
+// different result, for example for another tenant, do not fail the assertion.
+func AssertEvaluated(t testing.TB, flag string, enabled bool) bool {
+	t.Helper()
+
+	events := recorderFor(t).Flag(flag)
+	if len(events) == 0 {
+		t.Errorf("featurestest: flag %q was not evaluated", flag)
+		return false
+	}
+	if !slices.ContainsFunc(events, func(event features.Event) bool { return event.Enabled == enabled }) {
+		t.Errorf("featurestest: flag %q was evaluated %d times but never returned %v", flag, len(events), enabled)
+		return false
+	}
+	return true
+}
+
+// AssertNotEvaluated fails the test if the flag was evaluated since Record was called.
+func AssertNotEvaluated(t testing.TB, flag string) bool {
+	t.Helper()
+
+	if events := recorderFor(t).Flag(flag); len(events) > 0 {
+		t.Errorf("featurestest: flag %q was evaluated %d times", flag, len(events))
+		return false
+	}
+	return true
+}
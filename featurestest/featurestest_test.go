@@ -0,0 +1,48 @@
+package featurestest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/features-go"
+)
+
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+}
+
+func TestAssertEvaluated(t *testing.T) {
+	features.DefaultClient = features.NewClient("https://example.com", "foo-project", features.WithLocal(true), features.WithDisableStats(true))
+	features.DefaultClient.Override("disabled-flag", false)
+	Record(t)
+
+	require.True(t, features.Flag("enabled-flag"))
+	require.False(t, features.Flag("disabled-flag"))
+
+	AssertEvaluated(t, "enabled-flag", true)
+	AssertEvaluated(t, "disabled-flag", false)
+	AssertNotEvaluated(t, "other-flag")
+
+	ft := &fakeT{TB: t}
+	recordersMu.Lock()
+	recorders[ft] = recorders[t]
+	recordersMu.Unlock()
+	defer func() {
+		recordersMu.Lock()
+		delete(recorders, ft)
+		recordersMu.Unlock()
+	}()
+
+	require.False(t, AssertEvaluated(ft, "enabled-flag", false))
+	require.False(t, AssertEvaluated(ft, "other-flag", true))
+	require.False(t, AssertNotEvaluated(ft, "enabled-flag"))
+	require.Len(t, ft.errors, 3)
+}
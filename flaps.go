@@ -0,0 +1,106 @@
+package features
+
+import (
+	"bytes"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type flapOptions struct {
+	threshold int
+	window    time.Duration
+	fn        func(flag string, changes int)
+}
+
+// detectFlaps counts a change of each flag of the diff whose resolved values are
+// different in the new payload. Changes of the configuration that do not change the
+// result of any evaluation, like reordering the tenants, are not flaps.
+func (c *Client) detectFlaps(previous, fetched []flagReply, changed []string) {
+	if c.flaps == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, code := range changed {
+		if !resolvedChange(previous, fetched, code) {
+			continue
+		}
+
+		times := append(c.flapTimes[code], now)
+		times = slices.DeleteFunc(times, func(t time.Time) bool {
+			return now.Sub(t) > c.flaps.window
+		})
+		c.flapTimes[code] = times
+
+		if len(times) > c.flaps.threshold {
+			c.logger.Warn("feature flags: flag flapping", slog.String("flag", code), slog.Int("changes", len(times)))
+			if c.metrics != nil {
+				c.metrics.countFlap(code)
+			}
+			c.flaps.fn(code, len(times))
+
+			// Start counting again to notify once for each flapping episode.
+			delete(c.flapTimes, code)
+		}
+	}
+}
+
+// resolvedChange returns true if the flag evaluates to a different value in any of
+// the tenants, users and attributes configured in the flag before or after.
+func resolvedChange(before, after []flagReply, code string) bool {
+	before = flagsWithCode(before, code)
+	after = flagsWithCode(after, code)
+
+	var beforeValue, afterValue []byte
+	if len(before) > 0 {
+		beforeValue = before[0].Value
+	}
+	if len(after) > 0 {
+		afterValue = after[0].Value
+	}
+	if !bytes.Equal(beforeValue, afterValue) {
+		return true
+	}
+
+	type evalContext struct {
+		tenant, user string
+		attributes   map[string]string
+	}
+	contexts := []evalContext{{}}
+	for _, f := range slices.Concat(before, after) {
+		for _, t := range f.Tenants {
+			contexts = append(contexts, evalContext{tenant: t.Code})
+		}
+		for _, tenant := range f.ExcludedTenants {
+			contexts = append(contexts, evalContext{tenant: tenant})
+		}
+		for _, u := range f.Users {
+			contexts = append(contexts, evalContext{user: u.Code})
+		}
+		for _, rule := range f.Rules {
+			for _, value := range rule.Values {
+				contexts = append(contexts, evalContext{attributes: map[string]string{rule.Attribute: value}})
+			}
+		}
+	}
+	for _, ctx := range contexts {
+		b := traceEvaluate(before, code, ctx.tenant, ctx.user, ctx.attributes, nil, nil)
+		a := traceEvaluate(after, code, ctx.tenant, ctx.user, ctx.attributes, nil, nil)
+		if a.Enabled != b.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// flagsWithCode returns the flag with the code in a list of its own, or nil if it
+// is not in the flags.
+func flagsWithCode(flags []flagReply, code string) []flagReply {
+	for _, f := range flags {
+		if f.Code == code {
+			return []flagReply{f}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package features
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlapDetection(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var flapping []string
+		var changes []int
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithFlapDetection(2, time.Minute, func(flag string, n int) {
+			flapping = append(flapping, flag)
+			changes = append(changes, n)
+		}))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{}
+		DefaultClient.client = &http.Client{Transport: sources}
+		for i := range 5 {
+			sources["example.com"] = []flagReply{
+				{Code: "stable", Enabled: true},
+				{Code: "flapping", Enabled: i%2 == 0},
+			}
+			DefaultClient.fetch()
+			time.Sleep(DefaultClient.maxFetchInterval)
+		}
+
+		require.Equal(t, []string{"flapping"}, flapping)
+		require.Equal(t, []int{3}, changes)
+	})
+}
+
+func TestFlapDetectionOutsideWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var flapping []string
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithFlapDetection(2, 15*time.Second, func(flag string, n int) {
+			flapping = append(flapping, flag)
+		}))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{}
+		DefaultClient.client = &http.Client{Transport: sources}
+		for i := range 5 {
+			sources["example.com"] = []flagReply{
+				{Code: "flapping", Enabled: i%2 == 0},
+			}
+			DefaultClient.fetch()
+			time.Sleep(DefaultClient.maxFetchInterval)
+		}
+
+		require.Empty(t, flapping)
+	})
+}
+
+func TestFlapDetectionResolvedValues(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var flapping []string
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithFlapDetection(2, time.Minute, func(flag string, n int) {
+			flapping = append(flapping, flag)
+		}))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{}
+		DefaultClient.client = &http.Client{Transport: sources}
+		for i := range 5 {
+			// The configuration changes every time, but the flags evaluate the same.
+			tenants := []flagTenant{{Code: "foo", Enabled: true}, {Code: "bar", Enabled: false}}
+			if i%2 == 0 {
+				slices.Reverse(tenants)
+			}
+			sources["example.com"] = []flagReply{
+				{Code: "tenants", Enabled: true, Tenants: tenants},
+				{Code: "ttl", Enabled: true, TTL: int64(i + 1)},
+				{Code: "disabled-users", Enabled: false, Users: []flagTenant{{Code: "u1", Enabled: i%2 == 0}}},
+			}
+			DefaultClient.fetch()
+			time.Sleep(DefaultClient.maxFetchInterval)
+		}
+
+		require.Empty(t, flapping)
+	})
+}
+
+func TestFlapDetectionMetrics(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithFlapDetection(2, time.Minute, func(flag string, n int) {}))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.metrics = newMetrics("https://pushgateway.example.com", "test", "foo-host")
+
+		sources := fakeSources{}
+		DefaultClient.client = &http.Client{Transport: sources}
+		for i := range 7 {
+			sources["example.com"] = []flagReply{
+				{Code: "flapping", Enabled: true, Tenants: []flagTenant{{Code: "foo", Enabled: i%2 == 0}}},
+			}
+			DefaultClient.fetch()
+			time.Sleep(DefaultClient.maxFetchInterval)
+		}
+
+		require.Contains(t, string(DefaultClient.encodeMetrics()), `# TYPE features_flaps_total counter
+features_flaps_total{project="foo-project",flag="flapping"} 2
+`)
+	})
+}
@@ -1,8 +1,6 @@
 module github.com/altipla-consulting/features-go
 
-go 1.23.0
-
-toolchain go1.23.10
+go 1.25.0
 
 require github.com/altipla-consulting/env v0.3.0
 
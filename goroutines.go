@@ -0,0 +1,53 @@
+package features
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Goroutines returns the number of background goroutines the client is running.
+// It is zero after closing the client, so leak detectors can check it.
+func (c *Client) Goroutines() int {
+	return int(c.goroutines.Load())
+}
+
+// goBackground runs fn in a background goroutine that Close waits for. It returns
+// false without running it if the client is closing.
+func (c *Client) goBackground(name string, fn func()) bool {
+	if !c.addBackground() {
+		return false
+	}
+	start := func() {
+		go func() {
+			defer c.doneBackground()
+			fn()
+		}()
+	}
+
+	// The goroutine inherits the pprof labels, so the profiles attribute it to the client.
+	if c.goroutineLabels {
+		labels := pprof.Labels("component", "features", "features.project", c.project, "features.goroutine", name)
+		pprof.Do(context.Background(), labels, func(context.Context) { start() })
+	} else {
+		start()
+	}
+	return true
+}
+
+// addBackground registers a background operation unless the client is closing, so
+// it never races with the Wait of Close.
+func (c *Client) addBackground() bool {
+	c.closingMu.RLock()
+	defer c.closingMu.RUnlock()
+	if c.closing {
+		return false
+	}
+	c.wg.Add(1)
+	c.goroutines.Add(1)
+	return true
+}
+
+func (c *Client) doneBackground() {
+	c.goroutines.Add(-1)
+	c.wg.Done()
+}
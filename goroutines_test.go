@@ -0,0 +1,41 @@
+package features
+
+import (
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutines(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		client := NewClient("https://example.com", "foo-project", WithSink(new(fakeSink)))
+		require.Equal(t, 3, client.Goroutines())
+
+		require.NoError(t, client.Close())
+		require.Zero(t, client.Goroutines())
+	})
+}
+
+func TestGoroutinesFetchAfterClose(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		require.NoError(t, DefaultClient.Close())
+
+		DefaultClient.fetch()
+		require.Zero(t, tr.getRequests())
+	})
+}
+
+func TestGoroutineLabels(t *testing.T) {
+	client := NewClient("https://example.com", "foo-project", WithDisableStats(true), WithGoroutineLabels(true))
+	defer client.Close()
+
+	var buf strings.Builder
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	require.Contains(t, buf.String(), `"component":"features"`)
+	require.Contains(t, buf.String(), `"features.goroutine":"fetch"`)
+	require.Contains(t, buf.String(), `"features.project":"foo-project"`)
+}
@@ -0,0 +1,49 @@
+package features
+
+// handoff closes the previous client moving its state to the new one if both use the
+// same server and project.
+func (c *Client) handoff(previous *Client) {
+	if previous.evalURL != c.evalURL || previous.statsURL != c.statsURL {
+		previous.Close()
+		return
+	}
+
+	previous.mu.RLock()
+	flags, payload := previous.flags, previous.payload
+	stale, lastRefresh := previous.stale, previous.lastRefresh
+	previous.mu.RUnlock()
+
+	if !lastRefresh.IsZero() {
+		c.mu.Lock()
+		// The new client may have fetched newer flags already.
+		if c.lastRefresh.IsZero() {
+			c.flags = flags
+			c.rules.reset()
+			c.volatile = volatileFlags(flags)
+			c.payload = payload
+			c.stale = stale
+			c.lastRefresh = lastRefresh
+		}
+		c.mu.Unlock()
+
+		c.readyOnce.Do(func() {
+			close(c.ready)
+		})
+	}
+
+	// Without stats in the new client the previous one flushes them when closing.
+	if c.statsHandoff == nil {
+		previous.Close()
+		return
+	}
+
+	previous.skipFinalStats.Store(true)
+	previous.Close()
+
+	// The background goroutine of the previous client finished batching its stats, we
+	// can access them.
+	if len(previous.statsPending) > 0 {
+		c.statsHandoff <- previous.statsPending
+		previous.statsPending = nil
+	}
+}
@@ -0,0 +1,102 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureHandoff(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		require.True(t, Flag("global-enabled"))
+		previous := DefaultClient
+
+		Configure("https://example.com", "foo-project", WithHostname("foo-host"), WithLocal(false))
+		defer DefaultClient.Close()
+		DefaultClient.client = &http.Client{Transport: tr}
+
+		// Skipped because the flags moved from the previous client are recent.
+		DefaultClient.fetch()
+
+		// The previous client did not flush its stats when closing.
+		require.ErrorIs(t, previous.ctx.Err(), context.Canceled)
+		require.Empty(t, tr.sent)
+
+		// The new client has the flags without fetching them again.
+		require.True(t, DefaultClient.Ready())
+		require.True(t, Flag("global-enabled"))
+
+		time.Sleep(66 * time.Second)
+		synctest.Wait()
+
+		var hits int64
+		for _, stat := range tr.sent {
+			require.Equal(t, "global-enabled", stat.Flag)
+			hits += stat.TotalHits
+		}
+		require.EqualValues(t, 2, hits)
+	})
+}
+
+func TestConfigureHandoffOtherProject(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		require.True(t, Flag("global-enabled"))
+
+		Configure("https://example.com", "other-project")
+		defer DefaultClient.Close()
+
+		// The previous client flushed its stats and the flags were not moved.
+		require.Len(t, tr.sent, 1)
+		require.False(t, DefaultClient.Ready())
+	})
+}
+
+func TestConfigureShared(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = nil
+		require.NoError(t, ConfigureShared("https://example.com", "foo-project", WithDisableStats(true)))
+		defer DefaultClient.Close()
+		client := DefaultClient
+
+		require.NoError(t, ConfigureShared("https://example.com", "foo-project"))
+		require.Same(t, client, DefaultClient)
+
+		require.ErrorIs(t, ConfigureShared("https://example.com", "other-project"), ErrIncompatibleConfig)
+		require.ErrorIs(t, ConfigureShared("https://other.example.com", "foo-project"), ErrIncompatibleConfig)
+		require.ErrorIs(t, ConfigureShared("https://example.com", "foo-project", WithScope(map[string]string{"cluster": "eu-1"})), ErrIncompatibleConfig)
+		require.Same(t, client, DefaultClient)
+		require.NoError(t, client.ctx.Err())
+	})
+}
+
+func TestConfigureHandoffStatsMirror(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(fakeStats)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithStatsMirror(path))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+		require.True(t, Flag("global-enabled"))
+
+		Configure("https://example.com", "foo-project", WithLocal(false))
+		defer DefaultClient.Close()
+		DefaultClient.client = &http.Client{Transport: tr}
+
+		// The batch handed off to the new client was mirrored by the previous one.
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var line statsMirrorLine
+		require.NoError(t, json.Unmarshal(content, &line))
+		require.Equal(t, []StatEntry{{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 1, TotalHits: 1}}, line.Stats)
+	})
+}
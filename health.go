@@ -0,0 +1,44 @@
+package features
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReadyHandler returns a handler for readiness probes. It responds 200 once the
+// first fetch succeeded and the flags are not critically stale, and 503 otherwise.
+// Deployments that depend on the correct flags can use it to gate the traffic.
+func (c *Client) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.healthy() {
+			http.Error(w, "feature flags not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// LiveHandler returns a handler for liveness probes. It responds 200 while the
+// client is running, and 503 once it was closed.
+func (c *Client) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.ctx.Err() != nil {
+			http.Error(w, "feature flags client closed", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (c *Client) healthy() bool {
+	if c.local || c.static || c.noFetch {
+		return true
+	}
+	if !c.Ready() {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.lastRefresh) < c.criticalStaleness
+}
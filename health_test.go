@@ -0,0 +1,71 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func probe(h http.Handler) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestReadyHandler(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		require.Equal(t, http.StatusServiceUnavailable, probe(DefaultClient.ReadyHandler()))
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, http.StatusOK, probe(DefaultClient.ReadyHandler()))
+
+		tr.setDelay(4 * time.Second)
+		time.Sleep(31 * time.Minute)
+		require.Equal(t, http.StatusServiceUnavailable, probe(DefaultClient.ReadyHandler()))
+	})
+}
+
+func TestLiveHandler(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+
+		require.Equal(t, http.StatusOK, probe(DefaultClient.LiveHandler()))
+
+		DefaultClient.Close()
+		require.Equal(t, http.StatusServiceUnavailable, probe(DefaultClient.LiveHandler()))
+	})
+}
+
+func TestReadyHandlerCriticalStaleness(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := &fakeEval{}
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithCriticalStaleness(5*time.Minute))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, http.StatusOK, probe(DefaultClient.ReadyHandler()))
+
+		tr.setDelay(4 * time.Second)
+		time.Sleep(6 * time.Minute)
+		require.Equal(t, http.StatusServiceUnavailable, probe(DefaultClient.ReadyHandler()))
+	})
+}
+
+func TestReadyHandlerMaxStaleness(t *testing.T) {
+	client := NewClient("https://example.com", "foo-project", WithDisableStats(true), WithMaxStaleness(time.Hour))
+	defer client.Close()
+	require.Equal(t, time.Hour, client.criticalStaleness)
+
+	client = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+	defer client.Close()
+	require.Equal(t, 30*time.Minute, client.criticalStaleness)
+}
@@ -0,0 +1,95 @@
+package features
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metadataIdentityURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+
+// idTokenSource mints Google ID tokens with the metadata server and caches them
+// until they are about to expire.
+type idTokenSource struct {
+	audience    string
+	metadataURL string
+	client      *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newIDTokenSource(audience string) *idTokenSource {
+	return &idTokenSource{
+		audience:    audience,
+		metadataURL: metadataIdentityURL,
+		client:      http.DefaultClient,
+	}
+}
+
+func (s *idTokenSource) Token(req *http.Request) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Refresh with some margin to avoid sending a token that expires in flight.
+	if s.token != "" && time.Until(s.expires) > 5*time.Minute {
+		return s.token, nil
+	}
+
+	qs := make(url.Values)
+	qs.Set("audience", s.audience)
+	qs.Set("format", "full")
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, s.metadataURL+"?"+qs.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create id token request: %w", err)
+	}
+	tokenReq.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.client.Do(tokenReq)
+	if err != nil {
+		return "", fmt.Errorf("cannot request id token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected id token status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read id token: %w", err)
+	}
+
+	s.token = strings.TrimSpace(string(body))
+	s.expires = tokenExpiry(s.token)
+
+	return s.token, nil
+}
+
+// tokenExpiry reads the expiration of the JWT. Tokens of the metadata server last one
+// hour, that is used if the claim cannot be read.
+func tokenExpiry(token string) time.Time {
+	fallback := time.Now().Add(time.Hour)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
+}
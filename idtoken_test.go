@@ -0,0 +1,85 @@
+package features
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMetadata struct {
+	mu       sync.Mutex
+	requests int
+	audience string
+}
+
+func (c *fakeMetadata) getRequests() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests
+}
+
+func (c *fakeMetadata) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Metadata-Flavor") != "Google" {
+		return &http.Response{StatusCode: http.StatusForbidden, Body: http.NoBody}, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	c.audience = req.URL.Query().Get("audience")
+
+	payload := fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Hour).Unix())
+	token := "header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + fmt.Sprintf(".sig%d", c.requests)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(token)),
+	}, nil
+}
+
+func TestIDToken(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		metadata := new(fakeMetadata)
+		DefaultClient.idToken = newIDTokenSource("https://features.example.com")
+		DefaultClient.idToken.client = &http.Client{Transport: metadata}
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, "https://features.example.com", metadata.audience)
+		require.True(t, strings.HasSuffix(tr.getHeader().Get("Authorization"), ".sig1"))
+
+		// Cached while it is valid.
+		time.Sleep(30 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 1, metadata.getRequests())
+
+		// Refreshed before it expires.
+		time.Sleep(26 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 2, metadata.getRequests())
+		require.True(t, strings.HasSuffix(tr.getHeader().Get("Authorization"), ".sig2"))
+	})
+}
+
+func TestIDTokenWithAPIKey(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		DefaultClient.apiKey = "secret"
+		DefaultClient.idToken = newIDTokenSource("https://features.example.com")
+		DefaultClient.idToken.client = &http.Client{Transport: new(fakeMetadata)}
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, "Bearer secret", tr.getHeader().Get("Authorization"))
+		require.True(t, strings.HasSuffix(tr.getHeader().Get("X-Serverless-Authorization"), ".sig1"))
+	})
+}
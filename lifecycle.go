@@ -0,0 +1,82 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Evaluator evaluates the flags. Services built with dependency injection frameworks
+// can receive it instead of using the default client, and tests can replace it. The
+// generic ClientValue needs the concrete client and cannot be used through it.
+type Evaluator interface {
+	IsEnabled(flag, tenant string) bool
+	Detail(flag, tenant string) FlagDetail
+	DetailUser(flag, tenant, user string) FlagDetail
+	DetailAttributes(flag, tenant, user string, attributes map[string]string) FlagDetail
+	Percentage(code string, def int) int
+	JSON(code string, out any, opts ...FlagOption) bool
+}
+
+var _ Evaluator = (*Client)(nil)
+
+// NewEvaluator creates a client for the providers of dependency injection
+// frameworks. Unlike NewClient it returns an error instead of panicking if the
+// server, the project or the stats URL are not valid.
+//
+// It returns the client instead of the Evaluator interface because the generic
+// ClientValue needs it, and because the hooks of the lifecycle are registered with
+// its Start and Stop methods:
+//
+//	fx.Provide(func(lc fx.Lifecycle) (*features.Client, error) {
+//		client, err := features.NewEvaluator(serverURL, project)
+//		if err != nil {
+//			return nil, err
+//		}
+//		lc.Append(fx.Hook{OnStart: client.Start, OnStop: client.Stop})
+//		return client, nil
+//	})
+//
+// Services that only evaluate flags can receive it as an Evaluator.
+func NewEvaluator(serverURL, project string, opts ...ConfigureOption) (*Client, error) {
+	if project == "" {
+		return nil, errors.New("features: missing project")
+	}
+	if u, err := url.Parse(serverURL); err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("features: invalid server url %q", serverURL)
+	}
+	o := newConfigureOptions(opts)
+	if o.statsURL != "" {
+		if _, err := url.Parse(o.statsURL); err != nil {
+			return nil, fmt.Errorf("features: invalid stats url %q: %w", o.statsURL, err)
+		}
+	}
+	return newClient(serverURL, project, o), nil
+}
+
+// Start waits for the first fetch of the flags. It has the signature of the start
+// hooks of the lifecycle of dependency injection frameworks like fx, so the services
+// that depend on the client do not start before the flags are loaded.
+func (c *Client) Start(ctx context.Context) error {
+	if err := c.WaitForReady(ctx); err != nil {
+		return fmt.Errorf("features: cannot load the flags: %w", err)
+	}
+	return nil
+}
+
+// Stop closes the client flushing the pending stats, up to the deadline of the
+// context. It has the signature of the stop hooks of the lifecycle of dependency
+// injection frameworks like fx. The client keeps closing in the background if the
+// context expires before.
+func (c *Client) Stop(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Close() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("features: cannot close the client: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,53 @@
+package features
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartStop(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(0)
+
+		var evaluator Evaluator = DefaultClient
+		require.NoError(t, DefaultClient.Start(context.Background()))
+		require.True(t, DefaultClient.Ready())
+		require.True(t, evaluator.IsEnabled("global-enabled", ""))
+
+		require.NoError(t, DefaultClient.Stop(context.Background()))
+		require.Zero(t, DefaultClient.Goroutines())
+	})
+}
+
+func TestStartTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(4 * time.Second)
+		defer DefaultClient.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.ErrorIs(t, DefaultClient.Start(ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestNewEvaluator(t *testing.T) {
+	client, err := NewEvaluator("https://example.com", "foo-project", WithDisableStats(true))
+	require.NoError(t, err)
+	defer client.Close()
+
+	var evaluator Evaluator = client
+	require.NotNil(t, evaluator)
+
+	_, err = NewEvaluator("https://example.com", "")
+	require.EqualError(t, err, "features: missing project")
+
+	_, err = NewEvaluator("example.com", "foo-project")
+	require.EqualError(t, err, `features: invalid server url "example.com"`)
+
+	_, err = NewEvaluator("https://example.com", "foo-project", WithStatsURL("://stats"))
+	require.ErrorContains(t, err, "features: invalid stats url")
+}
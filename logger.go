@@ -0,0 +1,91 @@
+package features
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Logger is the minimal interface the client needs to log. *slog.Logger implements
+// it directly. Arguments are alternating keys and values, or slog.Attr values.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// ZapLogger is the subset of *zap.SugaredLogger used by the adapter.
+type ZapLogger interface {
+	Debugw(msg string, keysAndValues ...any)
+	Infow(msg string, keysAndValues ...any)
+	Warnw(msg string, keysAndValues ...any)
+	Errorw(msg string, keysAndValues ...any)
+}
+
+// FromZap adapts a zap sugared logger to be used by the client:
+//
+//	features.Configure(serverURL, project, features.WithLogger(features.FromZap(logger.Sugar())))
+func FromZap(logger ZapLogger) Logger {
+	return &zapLogger{logger}
+}
+
+type zapLogger struct {
+	logger ZapLogger
+}
+
+func (l *zapLogger) Debug(msg string, args ...any) { l.logger.Debugw(msg, keyValues(args)...) }
+func (l *zapLogger) Info(msg string, args ...any)  { l.logger.Infow(msg, keyValues(args)...) }
+func (l *zapLogger) Warn(msg string, args ...any)  { l.logger.Warnw(msg, keyValues(args)...) }
+func (l *zapLogger) Error(msg string, args ...any) { l.logger.Errorw(msg, keyValues(args)...) }
+
+// LogrusLogger is the subset of *logrus.Logger and *logrus.Entry used by the adapter.
+type LogrusLogger interface {
+	Debug(args ...any)
+	Info(args ...any)
+	Warn(args ...any)
+	Error(args ...any)
+}
+
+// FromLogrus adapts a logrus logger to be used by the client. The attributes are
+// appended to the message as key=value pairs.
+func FromLogrus(logger LogrusLogger) Logger {
+	return &logrusLogger{logger}
+}
+
+type logrusLogger struct {
+	logger LogrusLogger
+}
+
+func (l *logrusLogger) Debug(msg string, args ...any) { l.logger.Debug(formatMessage(msg, args)) }
+func (l *logrusLogger) Info(msg string, args ...any)  { l.logger.Info(formatMessage(msg, args)) }
+func (l *logrusLogger) Warn(msg string, args ...any)  { l.logger.Warn(formatMessage(msg, args)) }
+func (l *logrusLogger) Error(msg string, args ...any) { l.logger.Error(formatMessage(msg, args)) }
+
+// keyValues flattens the slog.Attr arguments to alternating keys and values.
+func keyValues(args []any) []any {
+	kvs := make([]any, 0, len(args))
+	for _, arg := range args {
+		if attr, ok := arg.(slog.Attr); ok {
+			kvs = append(kvs, attr.Key, attr.Value.Any())
+			continue
+		}
+		kvs = append(kvs, arg)
+	}
+	return kvs
+}
+
+func formatMessage(msg string, args []any) string {
+	kvs := keyValues(args)
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i < len(kvs); i += 2 {
+		if i+1 < len(kvs) {
+			fmt.Fprintf(&sb, " %v=%v", kvs[i], kvs[i+1])
+		} else {
+			fmt.Fprintf(&sb, " %v", kvs[i])
+		}
+	}
+	return sb.String()
+}
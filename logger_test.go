@@ -0,0 +1,67 @@
+package features
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeZap struct {
+	msg string
+	kvs []any
+}
+
+func (l *fakeZap) Debugw(msg string, kvs ...any) { l.msg, l.kvs = msg, kvs }
+func (l *fakeZap) Infow(msg string, kvs ...any)  { l.msg, l.kvs = msg, kvs }
+func (l *fakeZap) Warnw(msg string, kvs ...any)  { l.msg, l.kvs = msg, kvs }
+func (l *fakeZap) Errorw(msg string, kvs ...any) { l.msg, l.kvs = msg, kvs }
+
+func TestFromZap(t *testing.T) {
+	fake := new(fakeZap)
+	FromZap(fake).Warn("foo message", slog.String("error", "bar"), "key", 3)
+
+	require.Equal(t, "foo message", fake.msg)
+	require.Equal(t, []any{"error", "bar", "key", 3}, fake.kvs)
+}
+
+type fakeLogrus struct {
+	msg string
+}
+
+func (l *fakeLogrus) Debug(args ...any) { l.msg = fmt.Sprint(args...) }
+func (l *fakeLogrus) Info(args ...any)  { l.msg = fmt.Sprint(args...) }
+func (l *fakeLogrus) Warn(args ...any)  { l.msg = fmt.Sprint(args...) }
+func (l *fakeLogrus) Error(args ...any) { l.msg = fmt.Sprint(args...) }
+
+func TestFromLogrus(t *testing.T) {
+	fake := new(fakeLogrus)
+	FromLogrus(fake).Debug("foo message", slog.Duration("new", 15*time.Second), "key")
+
+	require.Equal(t, "foo message new=15s key", fake.msg)
+}
+
+type warnRecorder struct {
+	Logger
+	warnings []string
+}
+
+func (l *warnRecorder) Warn(msg string, args ...any) { l.warnings = append(l.warnings, msg) }
+
+func TestLoggerFetchFailed(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		logger := &warnRecorder{Logger: slog.New(slog.DiscardHandler)}
+		DefaultClient = NewClient("https://example.com", "foo-project", WithLogger(logger), WithDisableStats(true))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: &fakeEval{}}
+		defer DefaultClient.Close()
+
+		DefaultClient.InjectFaults(FaultInjection{FetchErrorRate: 1})
+		require.False(t, Flag("global-enabled"))
+		require.Equal(t, []string{"feature flags: fetch failed"}, logger.warnings)
+	})
+}
@@ -0,0 +1,73 @@
+package features
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoKey struct{}
+
+type evaluationMemo struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	results map[evaluationKey]memoResult
+}
+
+// evaluationKey identifies the memoized evaluations of a flag by their context.
+type evaluationKey struct {
+	code       string
+	tenant     string
+	user       string
+	attributes string
+}
+
+type memoResult struct {
+	detail FlagDetail
+	flags  []flagReply
+	at     time.Time
+}
+
+// MemoizeEvaluations returns a child context that reuses the result of the flags
+// evaluated with WithContext for the same tenant during the window, usually a few
+// milliseconds. Code that evaluates flags inside loops avoids the locks of the client
+// and registers a single hit in the stats for each window.
+func MemoizeEvaluations(ctx context.Context, window time.Duration) context.Context {
+	return context.WithValue(ctx, memoKey{}, &evaluationMemo{
+		window:  window,
+		results: make(map[evaluationKey]memoResult),
+	})
+}
+
+func contextMemo(o *flagOptions) *evaluationMemo {
+	if o.ctx == nil {
+		return nil
+	}
+	memo, _ := o.ctx.Value(memoKey{}).(*evaluationMemo)
+	return memo
+}
+
+// detail returns the memoized result of the evaluation and the flags used in it.
+func (memo *evaluationMemo) detail(code, tenant, user string, attributes map[string]string, evaluate func() (FlagDetail, []flagReply)) (FlagDetail, []flagReply) {
+	key := evaluationKey{
+		code:       code,
+		tenant:     tenant,
+		user:       user,
+		attributes: attributesKey(attributes),
+	}
+
+	memo.mu.Lock()
+	result, ok := memo.results[key]
+	memo.mu.Unlock()
+	if ok && time.Since(result.at) < memo.window {
+		return result.detail, result.flags
+	}
+
+	detail, flags := evaluate()
+
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	memo.results[key] = memoResult{detail: detail, flags: flags, at: time.Now()}
+	return detail, flags
+}
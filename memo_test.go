@@ -0,0 +1,54 @@
+package features
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoizeEvaluations(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFlags()
+		DefaultClient.stale = time.Now().Add(time.Hour)
+		DefaultClient.statsCh = make(chan accessEvent, 10)
+
+		ctx := MemoizeEvaluations(context.Background(), 5*time.Millisecond)
+		for range 3 {
+			require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+			require.False(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("bar-tenant")))
+		}
+		require.Len(t, DefaultClient.statsCh, 2)
+
+		// Different attributes are evaluated separately.
+		require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant"), WithAttribute("country", "ES")))
+		require.Len(t, DefaultClient.statsCh, 3)
+		<-DefaultClient.statsCh
+
+		// Flags changed after the window are evaluated again.
+		DefaultClient.flags = []flagReply{{Code: "tenant-enabled", Enabled: false}}
+		require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+		time.Sleep(5 * time.Millisecond)
+		require.False(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+		require.Len(t, DefaultClient.statsCh, 3)
+	})
+}
+
+func TestMemoizeEvaluationsKeys(t *testing.T) {
+	initFlags()
+	DefaultClient.stale = time.Now().Add(time.Hour)
+	DefaultClient.flags = append(DefaultClient.flags, flagReply{
+		Code:    "rules",
+		Enabled: true,
+		Rules:   []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: false}},
+	})
+
+	// Contexts whose codes would be equal if they were concatenated.
+	ctx := MemoizeEvaluations(context.Background(), time.Hour)
+	require.False(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant/x")))
+	require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant"), WithUser("x/")))
+	require.False(t, Flag("rules", WithContext(ctx), WithAttribute("country", "ES"), WithAttribute("plan", "pro")))
+	require.True(t, Flag("rules", WithContext(ctx), WithAttribute("country", "ES&plan=pro")))
+}
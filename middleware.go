@@ -0,0 +1,128 @@
+package features
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/altipla-consulting/env"
+)
+
+// TenantFunc resolves the tenant of an incoming request.
+type TenantFunc func(r *http.Request) string
+
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	debugHeader     bool
+	evaluatedHeader []string
+}
+
+// WithDebugHeader exposes the enabled flags of each request in the X-Features
+// response header. It is ignored in production environments.
+func WithDebugHeader() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.debugHeader = true
+	}
+}
+
+// WithEvaluatedHeader exposes the result of the allowed flags evaluated in the
+// snapshot of each request in the X-Features-Evaluated response header, like
+// "new-checkout=true,dark-mode=false". QA and E2E tests can check the features that
+// served the request. It is ignored in production environments.
+func WithEvaluatedHeader(flags ...string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.evaluatedHeader = flags
+	}
+}
+
+// Middleware stores a Snapshot of the flags in the context of each request, that
+// can be later retrieved with FromContext. The tenant func may be nil if the
+// service only uses global flags or the default tenant of the client.
+//
+// The middleware follows the standard net/http signature, so it can be used
+// directly with routers like chi. The featuresgin and featuresecho modules adapt it
+// to Gin and Echo.
+func Middleware(tenant TenantFunc, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	o := new(middlewareOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var t string
+			if tenant != nil {
+				t = tenant(r)
+			} else if DefaultClient != nil {
+				t = DefaultClient.defaultTenant
+			}
+			snap := NewSnapshot(t)
+
+			if o.debugHeader && !env.IsProduction() {
+				w.Header().Set("X-Features", strings.Join(snap.enabledFlags(), ","))
+			}
+
+			var evaluated *evaluatedWriter
+			if len(o.evaluatedHeader) > 0 && !env.IsProduction() {
+				evaluated = &evaluatedWriter{ResponseWriter: w, snap: snap, allowed: o.evaluatedHeader}
+				w = evaluated
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), snap)))
+
+			// Handlers that do not write anything send the headers when they return.
+			if evaluated != nil && !evaluated.written {
+				evaluated.setHeader()
+			}
+		})
+	}
+}
+
+// evaluatedWriter adds the evaluated flags header just before the response headers
+// are sent, when the handler already evaluated them.
+type evaluatedWriter struct {
+	http.ResponseWriter
+	snap    *Snapshot
+	allowed []string
+	written bool
+}
+
+func (w *evaluatedWriter) WriteHeader(code int) {
+	if !w.written {
+		w.setHeader()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *evaluatedWriter) setHeader() {
+	w.written = true
+	evaluated := w.snap.evaluated()
+	var values []string
+	for _, flag := range w.allowed {
+		if detail, ok := evaluated[flag]; ok {
+			values = append(values, flag+"="+strconv.FormatBool(detail.Enabled))
+		}
+	}
+	if len(values) > 0 {
+		w.Header().Set("X-Features-Evaluated", strings.Join(values, ","))
+	}
+}
+
+func (w *evaluatedWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *evaluatedWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *evaluatedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,90 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	initFlags()
+
+	tenant := func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	}
+	var enabled bool
+	h := Middleware(tenant)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled = FromContext(r.Context()).Flag("tenant-enabled")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant", "foo-tenant")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.True(t, enabled)
+	require.Empty(t, w.Header().Get("X-Features"))
+}
+
+func TestMiddlewareDebugHeader(t *testing.T) {
+	initFlags()
+
+	h := Middleware(nil, WithDebugHeader())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, "global-enabled", w.Header().Get("X-Features"))
+}
+
+func TestMiddlewareDebugHeaderOverridesAndLocal(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	initFlags()
+	DefaultClient.Override("global-disabled", true)
+
+	h := Middleware(nil, WithDebugHeader())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "global-disabled,global-enabled", w.Header().Get("X-Features"))
+
+	// Local clients enable every known flag.
+	Register("new-checkout")
+	DefaultClient.local = true
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "global-disabled,new-checkout", w.Header().Get("X-Features"))
+}
+
+func TestMiddlewareEvaluatedHeader(t *testing.T) {
+	initFlags()
+
+	h := Middleware(nil, WithEvaluatedHeader("global-enabled", "global-disabled", "not-evaluated"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap := FromContext(r.Context())
+		snap.Flag("global-disabled")
+		snap.Flag("global-enabled")
+		snap.Flag("tenant-enabled")
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, "global-enabled=true,global-disabled=false", w.Header().Get("X-Features-Evaluated"))
+	require.Equal(t, "ok", w.Body.String())
+}
+
+func TestMiddlewareEvaluatedHeaderEmptyResponse(t *testing.T) {
+	initFlags()
+
+	h := Middleware(nil, WithEvaluatedHeader("global-enabled"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Flag("global-enabled")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, "global-enabled=true", w.Header().Get("X-Features-Evaluated"))
+}
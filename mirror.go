@@ -0,0 +1,42 @@
+package features
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+)
+
+type statsMirrorLine struct {
+	Key string `json:"key"`
+	StatsRequest
+}
+
+func (c *Client) openStatsMirror(path string) {
+	if path == "" {
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		c.logger.Error("feature flags: cannot open stats mirror", slog.String("path", path), slog.String("error", err.Error()))
+		return
+	}
+	c.statsMirror = f
+}
+
+// mirrorStats writes the batch to the local mirror once, when it is created, so the
+// retries of the batch do not duplicate the lines. The key is the same idempotency key
+// sent to the server.
+func (c *Client) mirrorStats(batch statsBatch) {
+	if c.statsMirror == nil {
+		return
+	}
+
+	line := statsMirrorLine{
+		Key:          batch.key,
+		StatsRequest: c.newStatsRequest(batch),
+	}
+	if err := json.NewEncoder(c.statsMirror).Encode(line); err != nil {
+		c.logger.Error("feature flags: cannot write stats mirror", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,23 @@
+package features
+
+// Once runs fn when the flag gets enabled for the default tenant. It runs again if
+// the flag is later disabled and enabled again, but never twice while it is enabled.
+// If the flag is already enabled fn runs before returning; later changes run it in
+// the background goroutine of the client, so long setups should start their own
+// goroutine. The returned function stops watching the flag.
+func Once(code string, fn func()) (stop func()) {
+	if DefaultClient == nil {
+		return func() {}
+	}
+	return DefaultClient.Once(code, fn)
+}
+
+// Once runs fn when the flag gets enabled for the default tenant of the client. See
+// the package function Once for details.
+func (c *Client) Once(code string, fn func()) (stop func()) {
+	return c.Watch(code, func(enabled bool) {
+		if enabled {
+			fn()
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package features
+
+import (
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnce(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{
+			"example.com": {{Code: "foo", Enabled: true}},
+		}
+		DefaultClient.client = &http.Client{Transport: sources}
+
+		var runs int
+		stop := Once("foo", func() { runs++ })
+		require.Zero(t, runs)
+
+		DefaultClient.fetch()
+		synctest.Wait()
+		require.Equal(t, 1, runs)
+
+		// Still enabled.
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}, {Code: "bar", Enabled: true}}
+		DefaultClient.fetch()
+		require.Equal(t, 1, runs)
+
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: false}}
+		DefaultClient.fetch()
+		require.Equal(t, 1, runs)
+
+		// Enabled again.
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}}
+		DefaultClient.fetch()
+		require.Equal(t, 2, runs)
+
+		stop()
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: false}}
+		DefaultClient.fetch()
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}}
+		DefaultClient.fetch()
+		require.Equal(t, 2, runs)
+	})
+}
+
+func TestOnceAlreadyEnabled(t *testing.T) {
+	initFlags()
+	DefaultClient.ready = make(chan struct{})
+	close(DefaultClient.ready)
+
+	var runs int
+	stop := Once("global-enabled", func() { runs++ })
+	defer stop()
+	require.Equal(t, 1, runs)
+
+	Once("global-disabled", func() { runs++ })
+	require.Equal(t, 1, runs)
+}
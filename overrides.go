@@ -0,0 +1,123 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// overrideLayers in order of precedence.
+var overrideLayers = []Layer{LayerTest, LayerEnv, LayerFile}
+
+func (c *Client) loadOverrides(path string) {
+	c.overrides = make(map[Layer]map[string]bool)
+
+	if path != "" {
+		overrides, err := readOverridesFile(path)
+		if err != nil {
+			c.logger.Error("feature flags: cannot read overrides file", slog.String("path", path), slog.String("error", err.Error()))
+		} else {
+			c.overrides[LayerFile] = overrides
+		}
+	}
+
+	if value := os.Getenv("FEATURES_OVERRIDES"); value != "" {
+		overrides, err := parseOverridesEnv(value)
+		if err != nil {
+			c.logger.Error("feature flags: cannot parse FEATURES_OVERRIDES", slog.String("error", err.Error()))
+		} else {
+			c.overrides[LayerEnv] = overrides
+		}
+	}
+}
+
+func readOverridesFile(path string) (map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]bool)
+	if err := json.Unmarshal(content, &overrides); err != nil {
+		return nil, fmt.Errorf("cannot decode overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+func parseOverridesEnv(value string) (map[string]bool, error) {
+	overrides := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing value in override %q", item)
+		}
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in override %q: %w", item, err)
+		}
+		overrides[strings.TrimSpace(key)] = enabled
+	}
+	return overrides, nil
+}
+
+// override returns the value of the flag from the override layers if any of them
+// has it. Tenant specific overrides have precedence over the flag ones in the same
+// layer.
+func (c *Client) override(flag, tenant string) (FlagDetail, bool) {
+	c.overridesMu.RLock()
+	defer c.overridesMu.RUnlock()
+
+	if len(c.overrides) == 0 {
+		return FlagDetail{}, false
+	}
+
+	for _, layer := range overrideLayers {
+		overrides := c.overrides[layer]
+		if tenant != "" {
+			if enabled, ok := overrides[flag+"@"+tenant]; ok {
+				return FlagDetail{Enabled: enabled, Reason: ReasonOverride, Layer: layer}, true
+			}
+		}
+		if enabled, ok := overrides[flag]; ok {
+			return FlagDetail{Enabled: enabled, Reason: ReasonOverride, Layer: layer}, true
+		}
+	}
+
+	return FlagDetail{}, false
+}
+
+// Override forces the value of the flag, mostly for tests. It has precedence over
+// any other layer. Use WithTenant to override the value for a single tenant.
+func (c *Client) Override(code string, enabled bool, opts ...FlagOption) {
+	o := new(flagOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	key := code
+	if o.tenant != "" {
+		key += "@" + o.tenant
+	}
+
+	c.overridesMu.Lock()
+	defer c.overridesMu.Unlock()
+	if c.overrides == nil {
+		c.overrides = make(map[Layer]map[string]bool)
+	}
+	if c.overrides[LayerTest] == nil {
+		c.overrides[LayerTest] = make(map[string]bool)
+	}
+	c.overrides[LayerTest][key] = enabled
+}
+
+// ResetOverrides removes all the overrides set with Override.
+func (c *Client) ResetOverrides() {
+	c.overridesMu.Lock()
+	defer c.overridesMu.Unlock()
+	delete(c.overrides, LayerTest)
+}
@@ -0,0 +1,54 @@
+package features
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideLayers(t *testing.T) {
+	initFlags()
+
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"global-enabled": false, "global-disabled": true, "tenant-enabled@foo-tenant": false}`), 0600))
+	t.Setenv("FEATURES_OVERRIDES", "global-disabled=false")
+	DefaultClient.loadOverrides(path)
+
+	require.Equal(t, FlagDetail{Reason: ReasonOverride, Layer: LayerFile}, Detail("global-enabled"))
+	require.Equal(t, FlagDetail{Reason: ReasonOverride, Layer: LayerEnv}, Detail("global-disabled"))
+	require.Equal(t, FlagDetail{Reason: ReasonOverride, Layer: LayerFile}, Detail("tenant-enabled", WithTenant("foo-tenant")))
+	require.Equal(t, FlagDetail{Reason: ReasonTenantNotFound, Layer: LayerServer}, Detail("tenant-enabled", WithTenant("bar-tenant")))
+
+	DefaultClient.Override("global-disabled", true)
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonOverride, Layer: LayerTest}, Detail("global-disabled"))
+
+	DefaultClient.ResetOverrides()
+	require.Equal(t, FlagDetail{Reason: ReasonOverride, Layer: LayerEnv}, Detail("global-disabled"))
+}
+
+func TestOverrideLocal(t *testing.T) {
+	initFlags()
+	DefaultClient.local = true
+
+	DefaultClient.Override("global-enabled", false)
+	DefaultClient.Override("tenant-enabled", false, WithTenant("foo-tenant"))
+
+	require.False(t, Flag("global-enabled"))
+	require.False(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+	require.True(t, Flag("tenant-enabled", WithTenant("bar-tenant")))
+	require.True(t, Flag("not-found"))
+}
+
+func TestParseOverridesEnv(t *testing.T) {
+	overrides, err := parseOverridesEnv("foo=true, bar@acme=0,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"foo": true, "bar@acme": false}, overrides)
+
+	_, err = parseOverridesEnv("foo")
+	require.Error(t, err)
+
+	_, err = parseOverridesEnv("foo=maybe")
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package features
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type lastPayload struct {
+	raw       []byte
+	etag      string
+	fetchedAt time.Time
+}
+
+// LastPayload returns the raw JSON of the last successful fetch, when it was fetched
+// and its ETag, so it can be forwarded to other processes with the correct cache
+// headers. The ETag is the one sent by the server, or a hash of the content if there
+// is none or the flags were merged from multiple sources. It returns a nil payload
+// before the first fetch.
+func (c *Client) LastPayload() (raw []byte, fetchedAt time.Time, etag string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return bytes.Clone(c.payload.raw), c.payload.fetchedAt, c.payload.etag
+}
+
+func payloadETag(raw []byte) string {
+	hash := sha256.Sum256(raw)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// validatePayload checks that the evaluation of the flags does not depend on their
+// order in the payload. The error describes every problem found so it can be fixed
+// in the server with a single log line.
+func validatePayload(flags []flagReply) error {
+	var errs []error
+	seen := make(map[string]int, len(flags))
+	for i, f := range flags {
+		if f.Code == "" {
+			errs = append(errs, fmt.Errorf("flag at position %d has an empty code", i))
+			continue
+		}
+		if prev, ok := seen[f.Code]; ok {
+			errs = append(errs, fmt.Errorf("flag %q is duplicated at positions %d and %d", f.Code, prev, i))
+		} else {
+			seen[f.Code] = i
+		}
+		errs = append(errs, validateTenants(f.Code, "tenant", f.Tenants)...)
+		errs = append(errs, validateTenants(f.Code, "user", f.Users)...)
+		for j, r := range f.Rules {
+			if r.Attribute == "" {
+				errs = append(errs, fmt.Errorf("flag %q has an empty attribute in the rule at position %d", f.Code, j))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateTenants(flag, kind string, tenants []flagTenant) []error {
+	var errs []error
+	seen := make(map[string]bool, len(tenants))
+	for i, t := range tenants {
+		if t.Code == "" {
+			errs = append(errs, fmt.Errorf("flag %q has an empty %s code at position %d", flag, kind, i))
+			continue
+		}
+		if seen[t.Code] {
+			errs = append(errs, fmt.Errorf("flag %q has the %s %q duplicated", flag, kind, t.Code))
+		}
+		seen[t.Code] = true
+	}
+	return errs
+}
+
+// DuplicatePolicy decides how the client handles the payloads that contain the same
+// flag more than once.
+type DuplicatePolicy int
+
+const (
+	// DuplicateReject rejects the payload keeping the previous flags. It is the default.
+	DuplicateReject DuplicatePolicy = iota
+
+	// DuplicateLastWins uses the last entry of each flag in the payload. The detail
+	// of their evaluations is marked as Duplicated.
+	DuplicateLastWins
+)
+
+// dedupeFlags keeps the last entry of each flag, in the position of the first one.
+// It returns the codes of the duplicated flags.
+func dedupeFlags(flags []flagReply) ([]flagReply, []string) {
+	var deduped []flagReply
+	var duplicates []string
+	index := make(map[string]int, len(flags))
+	for _, f := range flags {
+		if i, ok := index[f.Code]; ok {
+			deduped[i] = f
+			if !slices.Contains(duplicates, f.Code) {
+				duplicates = append(duplicates, f.Code)
+			}
+			continue
+		}
+		index[f.Code] = len(deduped)
+		deduped = append(deduped, f)
+	}
+	if len(duplicates) == 0 {
+		return flags, nil
+	}
+	slices.Sort(duplicates)
+	return deduped, duplicates
+}
+
+// transformPayload applies the function configured with WithPayloadTransform to a
+// copy of the flags, so the payloads shared with other clients are never modified.
+// Flags keep being volatile if their code is not renamed.
+func (c *Client) transformPayload(flags []flagReply) ([]flagReply, error) {
+	configs := make([]FlagConfig, len(flags))
+	for i, f := range flags {
+		configs[i] = newFlagConfig(f)
+	}
+	volatile := volatileFlags(flags)
+	configs = c.transform(configs)
+
+	transformed := make([]flagReply, len(configs))
+	for i, config := range configs {
+		transformed[i] = newFlagReply(config, volatile[config.Code])
+	}
+	if err := validatePayload(transformed); err != nil {
+		return nil, fmt.Errorf("invalid transformed payload, keeping the previous flags: %w", err)
+	}
+	return transformed, nil
+}
@@ -0,0 +1,207 @@
+package features
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastPayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`[{"code":"global-enabled","enabled":true,"extra":"kept"}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer client.Close()
+
+	raw, fetchedAt, etag := client.LastPayload()
+	require.Nil(t, raw)
+	require.True(t, fetchedAt.IsZero())
+
+	require.NoError(t, client.WaitForReady(t.Context()))
+
+	raw, fetchedAt, etag = client.LastPayload()
+	require.JSONEq(t, `[{"code":"global-enabled","enabled":true,"extra":"kept"}]`, string(raw))
+	require.WithinDuration(t, time.Now(), fetchedAt, time.Minute)
+	require.Equal(t, `"v1"`, etag)
+}
+
+func TestLastPayloadMergedSources(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://team.example.com", "foo-project", WithDisableStats(true), WithOverrideSource("https://platform.example.com", "platform"))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: fakeSources{
+			"team.example.com":     {{Code: "global-enabled", Enabled: true}},
+			"platform.example.com": {{Code: "global-enabled", Enabled: false}},
+		}}
+		require.NoError(t, DefaultClient.WaitForReady(t.Context()))
+
+		raw, _, etag := DefaultClient.LastPayload()
+		var flags []flagReply
+		require.NoError(t, json.Unmarshal(raw, &flags))
+		require.Equal(t, []flagReply{{Code: "global-enabled", Enabled: false}}, flags)
+		require.Equal(t, payloadETag(raw), etag)
+	})
+}
+
+func TestValidatePayload(t *testing.T) {
+	require.NoError(t, validatePayload([]flagReply{
+		{Code: "foo", Tenants: []flagTenant{{Code: "acme"}, {Code: "other"}}},
+		{Code: "bar", Users: []flagTenant{{Code: "u1"}}},
+	}))
+
+	err := validatePayload([]flagReply{
+		{Code: "foo", Tenants: []flagTenant{{Code: "acme"}, {Code: "acme", Enabled: true}}},
+		{Code: ""},
+		{Code: "bar", Users: []flagTenant{{Code: ""}}},
+		{Code: "baz", Rules: []flagRule{{Attribute: "country", Values: []string{"ES"}}, {Values: []string{"pro"}}}},
+		{Code: "foo"},
+	})
+	require.EqualError(t, err, `flag "foo" has the tenant "acme" duplicated
+flag at position 1 has an empty code
+flag "bar" has an empty user code at position 0
+flag "baz" has an empty attribute in the rule at position 1
+flag "foo" is duplicated at positions 0 and 4`)
+}
+
+func TestFetchInvalidPayload(t *testing.T) {
+	var invalid atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if invalid.Load() {
+			_, _ = w.Write([]byte(`[{"code":"foo","enabled":false},{"code":"foo","enabled":true}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"code":"foo","enabled":true}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer client.Close()
+	require.NoError(t, client.WaitForReady(t.Context()))
+	previous, _, _ := client.LastPayload()
+
+	invalid.Store(true)
+	client.mu.Lock()
+	client.lastRefresh = time.Time{}
+	client.mu.Unlock()
+	client.fetch()
+
+	raw, _, _ := client.LastPayload()
+	require.Equal(t, previous, raw)
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, client.Detail("foo", ""))
+}
+
+func TestDedupeFlags(t *testing.T) {
+	flags := []flagReply{{Code: "foo"}, {Code: "bar"}}
+	deduped, duplicates := dedupeFlags(flags)
+	require.Equal(t, flags, deduped)
+	require.Nil(t, duplicates)
+
+	deduped, duplicates = dedupeFlags([]flagReply{
+		{Code: "foo", Enabled: false},
+		{Code: "bar"},
+		{Code: "foo", Enabled: true},
+		{Code: "bar", Enabled: true},
+		{Code: "foo", Enabled: true, Volatile: true},
+	})
+	require.Equal(t, []flagReply{{Code: "foo", Enabled: true, Volatile: true}, {Code: "bar", Enabled: true}}, deduped)
+	require.Equal(t, []string{"bar", "foo"}, duplicates)
+}
+
+func TestFetchDuplicateLastWins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"code":"foo","enabled":false},{"code":"bar","enabled":true},{"code":"foo","enabled":true}]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithDuplicatePolicy(DuplicateLastWins), WithPushgateway("http://localhost:1", "test"))
+	defer client.Close()
+	require.NoError(t, client.WaitForReady(t.Context()))
+
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer, Duplicated: true}, client.Detail("foo", ""))
+	require.Equal(t, FlagDetail{Enabled: true, Reason: ReasonGlobal, Layer: LayerServer}, client.Detail("bar", ""))
+	require.EqualValues(t, 1, client.metrics.duplicateFlags.Load())
+
+	raw, _, etag := client.LastPayload()
+	require.JSONEq(t, `[{"code":"foo","enabled":true,"tenants":null},{"code":"bar","enabled":true,"tenants":null}]`, string(raw))
+	require.Equal(t, payloadETag(raw), etag)
+}
+
+func TestFetchPayloadTransform(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"code":"billing-foo","enabled":true,"volatile":true},{"code":"billing-bar","enabled":true},{"code":"search","enabled":true}]`))
+	}))
+	defer server.Close()
+
+	transform := func(flags []FlagConfig) []FlagConfig {
+		for i := range flags {
+			if strings.HasPrefix(flags[i].Code, "billing-") {
+				flags[i].Enabled = false
+			}
+		}
+		flags[2].Code = "search-v2"
+		return append(flags, FlagConfig{Code: "injected", Enabled: true})
+	}
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithPayloadTransform(transform))
+	defer client.Close()
+	other := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer other.Close()
+	require.NoError(t, client.WaitForReady(t.Context()))
+	require.NoError(t, other.WaitForReady(t.Context()))
+
+	require.False(t, client.IsEnabled("billing-foo", ""))
+	require.False(t, client.IsEnabled("billing-bar", ""))
+	require.True(t, client.IsEnabled("search-v2", ""))
+	require.True(t, client.IsEnabled("injected", ""))
+	require.Equal(t, ReasonNotFound, client.Detail("search", "").Reason)
+	require.True(t, client.volatile["billing-foo"])
+
+	raw, _, etag := client.LastPayload()
+	require.JSONEq(t, `[
+		{"code":"billing-foo","enabled":false,"volatile":true,"tenants":null},
+		{"code":"billing-bar","enabled":false,"tenants":null},
+		{"code":"search-v2","enabled":true,"tenants":null},
+		{"code":"injected","enabled":true,"tenants":null}
+	]`, string(raw))
+	require.Equal(t, payloadETag(raw), etag)
+
+	// The flags shared with other clients of the same server are not modified.
+	require.True(t, other.IsEnabled("billing-foo", ""))
+	require.True(t, other.IsEnabled("search", ""))
+}
+
+func TestFetchPayloadTransformInvalid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"code":"foo","enabled":true}]`))
+	}))
+	defer server.Close()
+
+	var duplicate atomic.Bool
+	transform := func(flags []FlagConfig) []FlagConfig {
+		if duplicate.Load() {
+			return append(flags, FlagConfig{Code: "foo"})
+		}
+		return flags
+	}
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithPayloadTransform(transform))
+	defer client.Close()
+	require.NoError(t, client.WaitForReady(t.Context()))
+
+	duplicate.Store(true)
+	client.mu.Lock()
+	client.lastRefresh = time.Time{}
+	client.mu.Unlock()
+	client.fetch()
+
+	require.True(t, client.IsEnabled("foo", ""))
+}
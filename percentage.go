@@ -0,0 +1,38 @@
+package features
+
+import (
+	"encoding/json"
+)
+
+// Percentage returns the value between 0 and 100 of the flag from the default client,
+// for traffic shaping decisions like sampling rates or canary weights. It returns def
+// if the flag does not exist or it has no numeric value, and 0 if the flag is disabled.
+func Percentage(code string, def int) int {
+	if DefaultClient == nil {
+		return def
+	}
+	return DefaultClient.Percentage(code, def)
+}
+
+// Percentage returns the value between 0 and 100 of the flag. It returns def if the
+// flag does not exist or it has no numeric value, and 0 if the flag is disabled. The
+// flag is evaluated like Detail for the default tenant of the client, so the
+// overrides and the frozen flags apply.
+func (c *Client) Percentage(code string, def int) int {
+	detail, flags := c.detailFlags(code, c.defaultTenant, "", nil)
+	if detail.Reason == ReasonNotFound {
+		return def
+	}
+	if !detail.Enabled {
+		return 0
+	}
+	return percentage(c.rawValue(flags, code), def)
+}
+
+func percentage(raw json.RawMessage, def int) int {
+	var value float64
+	if len(raw) == 0 || json.Unmarshal(raw, &value) != nil {
+		return def
+	}
+	return min(max(int(value), 0), 100)
+}
@@ -0,0 +1,58 @@
+package features
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentage(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "sampling", Enabled: true, Value: json.RawMessage("25")},
+		{Code: "disabled", Enabled: false, Value: json.RawMessage("25")},
+		{Code: "overflow", Enabled: true, Value: json.RawMessage("150")},
+		{Code: "boolean", Enabled: true},
+		{Code: "invalid", Enabled: true, Value: json.RawMessage(`"foo"`)},
+	}
+
+	require.Equal(t, 25, Percentage("sampling", 10))
+	require.Equal(t, 0, Percentage("disabled", 10))
+	require.Equal(t, 100, Percentage("overflow", 10))
+	require.Equal(t, 10, Percentage("boolean", 10))
+	require.Equal(t, 10, Percentage("invalid", 10))
+	require.Equal(t, 10, Percentage("not-found", 10))
+}
+
+func TestPercentageOverridesAndFrozen(t *testing.T) {
+	initFlags()
+	frozen := false
+	DefaultClient.flags = []flagReply{
+		{Code: "sampling", Enabled: true, Value: json.RawMessage("25")},
+		{Code: "disabled", Enabled: false, Value: json.RawMessage("25")},
+		{Code: "frozen", Enabled: true, Frozen: &frozen, Value: json.RawMessage("25")},
+	}
+	DefaultClient.Override("sampling", false)
+	DefaultClient.Override("disabled", true)
+
+	require.Equal(t, 0, Percentage("sampling", 10))
+	require.Equal(t, 25, Percentage("disabled", 10))
+	require.Equal(t, 0, Percentage("frozen", 10))
+}
+
+func TestPercentageDefaultTenant(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "sampling", Enabled: true, Value: json.RawMessage("25"), Tenants: []flagTenant{{Code: "foo-tenant", Enabled: true}}},
+	}
+	require.Equal(t, 0, Percentage("sampling", 10))
+
+	DefaultClient.defaultTenant = "foo-tenant"
+	require.Equal(t, 25, Percentage("sampling", 10))
+}
+
+func TestPercentageUnconfigured(t *testing.T) {
+	DefaultClient = nil
+	require.Equal(t, 10, Percentage("sampling", 10))
+}
@@ -0,0 +1,154 @@
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metrics counts the activity of the client to push it to a Prometheus Pushgateway.
+type metrics struct {
+	pushURL string
+
+	fetches        atomic.Int64
+	fetchErrors    atomic.Int64
+	duplicateFlags atomic.Int64
+
+	// Evaluations that used the defaults because the flags exceeded the maximum staleness.
+	staleEvaluations atomic.Int64
+
+	mu          sync.Mutex
+	evaluations map[string]*flagEvaluations
+
+	// Flapping episodes detected for each flag with WithFlapDetection.
+	flaps map[string]int64
+}
+
+type flagEvaluations struct {
+	enabled, disabled int64
+}
+
+func newMetrics(gatewayURL, job, instance string) *metrics {
+	return &metrics{
+		pushURL:     strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job) + "/instance/" + url.PathEscape(instance),
+		evaluations: make(map[string]*flagEvaluations),
+		flaps:       make(map[string]int64),
+	}
+}
+
+func (m *metrics) countEvaluation(flag string, enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts, ok := m.evaluations[flag]
+	if !ok {
+		counts = new(flagEvaluations)
+		m.evaluations[flag] = counts
+	}
+	if enabled {
+		counts.enabled++
+	} else {
+		counts.disabled++
+	}
+}
+
+func (m *metrics) countFlap(flag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flaps[flag]++
+}
+
+// encode the metrics in the Prometheus text format.
+func (c *Client) encodeMetrics() []byte {
+	var buf bytes.Buffer
+	project := escapeLabel(c.project)
+
+	fmt.Fprintf(&buf, "# TYPE features_fetches_total counter\n")
+	fmt.Fprintf(&buf, "features_fetches_total{project=\"%s\"} %d\n", project, c.metrics.fetches.Load())
+	fmt.Fprintf(&buf, "# TYPE features_fetch_errors_total counter\n")
+	fmt.Fprintf(&buf, "features_fetch_errors_total{project=\"%s\"} %d\n", project, c.metrics.fetchErrors.Load())
+	fmt.Fprintf(&buf, "# TYPE features_duplicate_flags_total counter\n")
+	fmt.Fprintf(&buf, "features_duplicate_flags_total{project=\"%s\"} %d\n", project, c.metrics.duplicateFlags.Load())
+	fmt.Fprintf(&buf, "# TYPE features_stale_evaluations_total counter\n")
+	fmt.Fprintf(&buf, "features_stale_evaluations_total{project=\"%s\"} %d\n", project, c.metrics.staleEvaluations.Load())
+
+	c.mu.RLock()
+	lastRefresh := c.lastRefresh
+	c.mu.RUnlock()
+	if !lastRefresh.IsZero() {
+		fmt.Fprintf(&buf, "# TYPE features_last_refresh_timestamp_seconds gauge\n")
+		fmt.Fprintf(&buf, "features_last_refresh_timestamp_seconds{project=\"%s\"} %d\n", project, lastRefresh.Unix())
+	}
+
+	if rates := c.AccessRates(); len(rates) > 0 {
+		fmt.Fprintf(&buf, "# TYPE features_evaluation_rate gauge\n")
+		for _, rate := range rates {
+			fmt.Fprintf(&buf, "features_evaluation_rate{project=\"%s\",flag=\"%s\"} %g\n", project, escapeLabel(rate.Flag), rate.QPS)
+		}
+	}
+
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+	fmt.Fprintf(&buf, "# TYPE features_evaluations_total counter\n")
+	for _, flag := range slices.Sorted(maps.Keys(c.metrics.evaluations)) {
+		counts := c.metrics.evaluations[flag]
+		fmt.Fprintf(&buf, "features_evaluations_total{project=\"%s\",flag=\"%s\",enabled=\"true\"} %d\n", project, escapeLabel(flag), counts.enabled)
+		fmt.Fprintf(&buf, "features_evaluations_total{project=\"%s\",flag=\"%s\",enabled=\"false\"} %d\n", project, escapeLabel(flag), counts.disabled)
+	}
+	if len(c.metrics.flaps) > 0 {
+		fmt.Fprintf(&buf, "# TYPE features_flaps_total counter\n")
+		for _, flag := range slices.Sorted(maps.Keys(c.metrics.flaps)) {
+			fmt.Fprintf(&buf, "features_flaps_total{project=\"%s\",flag=\"%s\"} %d\n", project, escapeLabel(flag), c.metrics.flaps[flag])
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// pushMetrics sends the metrics to the Pushgateway replacing the previous ones of
+// the same job and instance.
+func (c *Client) pushMetrics() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.metrics.pushURL, bytes.NewReader(c.encodeMetrics()))
+	if err != nil {
+		return fmt.Errorf("cannot create pushgateway request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected pushgateway status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) closeMetrics() {
+	if c.metrics == nil {
+		return
+	}
+	if err := c.pushMetrics(); err != nil {
+		c.logger.Error("feature flags: failed to push metrics", slog.String("error", err.Error()))
+		c.reportError("feature flags: failed to push metrics: %w", err)
+		c.closeError(fmt.Errorf("cannot push metrics: %w", err))
+	}
+}
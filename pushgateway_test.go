@@ -0,0 +1,65 @@
+package features
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakePushgateway struct {
+	method string
+	path   string
+	body   string
+}
+
+func (c *fakePushgateway) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "pushgateway.example.com" {
+		var buf bytes.Buffer
+		_ = json.NewEncoder(&buf).Encode([]flagReply{{Code: "global-enabled", Enabled: true}})
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(&buf)}, nil
+	}
+
+	content, _ := io.ReadAll(req.Body)
+	c.method = req.Method
+	c.path = req.URL.Path
+	c.body = string(content)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestPushgateway(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(fakePushgateway)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithHostname("foo-host"), WithPushgateway("https://pushgateway.example.com", "cleanup"))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+
+		require.True(t, Flag("global-enabled"))
+		require.True(t, Flag("global-enabled"))
+		require.False(t, Flag("unknown"))
+		DefaultClient.Close()
+
+		require.Equal(t, http.MethodPut, tr.method)
+		require.Equal(t, "/metrics/job/cleanup/instance/foo-host", tr.path)
+		require.Equal(t, `# TYPE features_fetches_total counter
+features_fetches_total{project="foo-project"} 1
+# TYPE features_fetch_errors_total counter
+features_fetch_errors_total{project="foo-project"} 0
+# TYPE features_duplicate_flags_total counter
+features_duplicate_flags_total{project="foo-project"} 0
+# TYPE features_stale_evaluations_total counter
+features_stale_evaluations_total{project="foo-project"} 0
+# TYPE features_last_refresh_timestamp_seconds gauge
+features_last_refresh_timestamp_seconds{project="foo-project"} 946684800
+# TYPE features_evaluations_total counter
+features_evaluations_total{project="foo-project",flag="global-enabled",enabled="true"} 2
+features_evaluations_total{project="foo-project",flag="global-enabled",enabled="false"} 0
+features_evaluations_total{project="foo-project",flag="unknown",enabled="true"} 0
+features_evaluations_total{project="foo-project",flag="unknown",enabled="false"} 1
+`, tr.body)
+	})
+}
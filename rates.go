@@ -0,0 +1,114 @@
+package features
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ratesWindow is the period of the evaluations used to compute the access rates.
+const ratesWindow = time.Hour
+
+// AccessRate is the number of evaluations of a flag in the last hour.
+type AccessRate struct {
+	Flag        string
+	Evaluations int64
+
+	// QPS is the average of evaluations per second in the last hour, or since the
+	// client was created if it is more recent.
+	QPS float64
+}
+
+// accessRates keeps the evaluations per minute of each flag in the last hour. They
+// are counted by the stats collector so they survive the flushes of the stats.
+type accessRates struct {
+	mu      sync.Mutex
+	started time.Time
+	flags   map[string]map[int64]int64 // evaluations by minute bucket
+	current int64                      // last bucket counted, to prune the old ones once per minute
+}
+
+func newAccessRates() *accessRates {
+	return &accessRates{
+		started: time.Now(),
+		flags:   make(map[string]map[int64]int64),
+	}
+}
+
+func (r *accessRates) count(flag string, bucket int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Services that never read the rates would keep the old buckets forever.
+	if bucket != r.current {
+		r.current = bucket
+		r.prune(time.UnixMilli(bucket).Add(-ratesWindow).UnixMilli())
+	}
+
+	buckets, ok := r.flags[flag]
+	if !ok {
+		buckets = make(map[int64]int64)
+		r.flags[flag] = buckets
+	}
+	buckets[bucket]++
+}
+
+// prune removes the buckets older than the cutoff. It should be called with the
+// lock held.
+func (r *accessRates) prune(cutoff int64) {
+	for flag, buckets := range r.flags {
+		for bucket := range buckets {
+			if bucket < cutoff {
+				delete(buckets, bucket)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(r.flags, flag)
+		}
+	}
+}
+
+// snapshot returns the rates of the flags evaluated in the window that ends at now.
+func (r *accessRates) snapshot(now time.Time) []AccessRate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := now.Add(-ratesWindow).Truncate(time.Minute).UnixMilli()
+	elapsed := min(max(time.Since(r.started), time.Minute), ratesWindow)
+
+	r.prune(cutoff)
+
+	var rates []AccessRate
+	for flag, buckets := range r.flags {
+		var evaluations int64
+		for _, hits := range buckets {
+			evaluations += hits
+		}
+		rates = append(rates, AccessRate{
+			Flag:        flag,
+			Evaluations: evaluations,
+			QPS:         float64(evaluations) / elapsed.Seconds(),
+		})
+	}
+	slices.SortFunc(rates, func(a, b AccessRate) int {
+		return cmp.Or(cmp.Compare(b.Evaluations, a.Evaluations), cmp.Compare(a.Flag, b.Flag))
+	})
+	return rates
+}
+
+// AccessRates returns the evaluations of each flag in the last hour with the default
+// client, sorted from the hottest flag.
+func AccessRates() []AccessRate {
+	if DefaultClient == nil {
+		return nil
+	}
+	return DefaultClient.AccessRates()
+}
+
+// AccessRates returns the evaluations of each flag in the last hour, sorted from the
+// hottest flag. They come from the stats collector, so they are empty if the stats
+// are disabled and they do not include the frozen flags.
+func (c *Client) AccessRates() []AccessRate {
+	return c.rates.snapshot(c.statsNow())
+}
@@ -0,0 +1,57 @@
+package features
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessRates(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initStats()
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		require.False(t, Flag("global-disabled"))
+		require.False(t, Flag("global-disabled"))
+		synctest.Wait()
+		require.Equal(t, []AccessRate{
+			{Flag: "global-disabled", Evaluations: 2, QPS: 2.0 / 60},
+			{Flag: "global-enabled", Evaluations: 1, QPS: 1.0 / 60},
+		}, AccessRates())
+
+		// The rates are kept after the stats are flushed.
+		time.Sleep(30 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+		synctest.Wait()
+		require.Equal(t, []AccessRate{
+			{Flag: "global-disabled", Evaluations: 2, QPS: 2.0 / 1800},
+			{Flag: "global-enabled", Evaluations: 2, QPS: 2.0 / 1800},
+		}, AccessRates())
+
+		// The evaluations older than an hour are discarded.
+		time.Sleep(45 * time.Minute)
+		require.Equal(t, []AccessRate{
+			{Flag: "global-enabled", Evaluations: 1, QPS: 1.0 / 3600},
+		}, AccessRates())
+	})
+}
+
+func TestAccessRatesUnconfigured(t *testing.T) {
+	DefaultClient = nil
+	require.Nil(t, AccessRates())
+}
+
+func TestAccessRatesPrune(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rates := newAccessRates()
+	rates.count("old", start.UnixMilli())
+	rates.count("recent", start.Add(30*time.Minute).UnixMilli())
+	require.Len(t, rates.flags, 2)
+
+	rates.count("recent", start.Add(61*time.Minute).UnixMilli())
+	require.Len(t, rates.flags, 1)
+	require.Len(t, rates.flags["recent"], 2)
+}
@@ -0,0 +1,101 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when waiting on a client that was closed.
+	ErrClosed = errors.New("features: client closed")
+
+	// ErrNotConfigured is returned when waiting without configuring the default client.
+	ErrNotConfigured = errors.New("features: client not configured")
+)
+
+// WaitForReady blocks until the first successful fetch of the flags, retrying it if
+// needed. It returns the context error if it expires before. Services can use it
+// to refuse traffic until the flags are loaded.
+func (c *Client) WaitForReady(ctx context.Context) error {
+	if c.local {
+		return nil
+	}
+
+	for {
+		if c.Ready() {
+			return nil
+		}
+
+		c.fetch()
+
+		select {
+		case <-c.ready:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.ctx.Done():
+			return ErrClosed
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// WaitForFlag blocks until the flag is enabled for the default tenant. It returns
+// the context error if it expires before. Jobs can use it to wait for an
+// operational flag that is flipped centrally, like the end of a migration.
+func WaitForFlag(ctx context.Context, code string) error {
+	if DefaultClient == nil {
+		return ErrNotConfigured
+	}
+	return DefaultClient.WaitForFlag(ctx, code)
+}
+
+// WaitForFlag blocks until the flag is enabled for the default tenant of the client.
+// See the package function WaitForFlag for details.
+func (c *Client) WaitForFlag(ctx context.Context, code string) error {
+	enabled := make(chan struct{})
+	notify := sync.OnceFunc(func() { close(enabled) })
+	stop := c.Watch(code, func(current bool) {
+		if current {
+			notify()
+		}
+	})
+	defer stop()
+
+	for {
+		// Keep fetching the flags even if the process does not evaluate them.
+		c.fetch()
+
+		select {
+		case <-enabled:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.ctx.Done():
+			return ErrClosed
+		case <-time.After(c.maxFetchInterval):
+		}
+	}
+}
+
+// Ready returns true if the default client fetched the flags successfully at least
+// once. The first evaluation of a flag fetches them immediately if they were not
+// fetched yet, so evaluations before it is ready may return the defaults.
+func Ready() bool {
+	if DefaultClient == nil {
+		return false
+	}
+	return DefaultClient.Ready()
+}
+
+// Ready returns true if the flags were fetched successfully at least once.
+func (c *Client) Ready() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
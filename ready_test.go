@@ -0,0 +1,94 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForReady(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		require.False(t, DefaultClient.Ready())
+		require.NoError(t, DefaultClient.WaitForReady(context.Background()))
+		require.True(t, DefaultClient.Ready())
+		require.Equal(t, 1, tr.getRequests())
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, 1, tr.getRequests())
+	})
+}
+
+func TestReadyFirstEvaluation(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		require.False(t, Ready())
+		require.True(t, Flag("global-enabled"))
+		require.True(t, Ready())
+		require.Equal(t, 1, tr.getRequests())
+	})
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(4 * time.Second)
+		defer DefaultClient.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.ErrorIs(t, DefaultClient.WaitForReady(ctx), context.DeadlineExceeded)
+		require.False(t, DefaultClient.Ready())
+	})
+}
+
+func TestWaitForReadyClosed(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(4 * time.Second)
+		DefaultClient.Close()
+
+		require.ErrorIs(t, DefaultClient.WaitForReady(context.Background()), ErrClosed)
+	})
+}
+
+type fakeToggle struct {
+	enabled atomic.Bool
+}
+
+func (c *fakeToggle) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fakeSources{
+		req.URL.Host: {{Code: "migration-completed", Enabled: c.enabled.Load()}},
+	}.RoundTrip(req)
+}
+
+func TestWaitForFlag(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		tr := new(fakeToggle)
+		DefaultClient.client = &http.Client{Transport: tr}
+		DefaultClient.fetch()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.ErrorIs(t, WaitForFlag(ctx, "migration-completed"), context.DeadlineExceeded)
+
+		done := make(chan error)
+		go func() {
+			done <- WaitForFlag(context.Background(), "migration-completed")
+		}()
+		time.Sleep(time.Minute)
+		tr.enabled.Store(true)
+		require.NoError(t, <-done)
+	})
+}
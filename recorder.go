@@ -0,0 +1,86 @@
+package features
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Recorder keeps a log in memory of the evaluations of a client. Tests can use it
+// to check the flags consulted by a code path, and staging instances can enable it
+// behind a debug flag to inspect the evaluations. Clients without recorders do
+// not pay for them in the evaluations.
+type Recorder struct {
+	stop func()
+
+	mu     sync.Mutex
+	max    int
+	events []Event
+}
+
+// NewRecorder starts recording the evaluations of the client. It keeps the last max
+// evaluations, or all of them if max is zero.
+func NewRecorder(client *Client, max int) *Recorder {
+	r := &Recorder{max: max}
+	r.stop = client.OnEvaluation(r.record)
+	return r
+}
+
+func (r *Recorder) record(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.max > 0 && len(r.events) >= r.max {
+		r.events = slices.Delete(r.events, 0, len(r.events)-r.max+1)
+	}
+	r.events = append(r.events, event)
+}
+
+// Close stops recording the evaluations. The recorded ones can still be queried.
+func (r *Recorder) Close() {
+	r.stop()
+}
+
+// Reset discards the recorded evaluations.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// Evaluations returns the recorded evaluations, from older to newer.
+func (r *Recorder) Evaluations() []Event {
+	return r.Filter(func(Event) bool { return true })
+}
+
+// Flag returns the recorded evaluations of the flag.
+func (r *Recorder) Flag(code string) []Event {
+	return r.Filter(func(event Event) bool { return event.Flag == code })
+}
+
+// Tenant returns the recorded evaluations of any flag for the tenant.
+func (r *Recorder) Tenant(tenant string) []Event {
+	return r.Filter(func(event Event) bool { return event.Tenant == tenant })
+}
+
+// Since returns the evaluations recorded after the time.
+func (r *Recorder) Since(t time.Time) []Event {
+	return r.Filter(func(event Event) bool { return event.Time.After(t) })
+}
+
+// Evaluated returns true if the flag was evaluated with the result.
+func (r *Recorder) Evaluated(code string, enabled bool) bool {
+	return len(r.Filter(func(event Event) bool { return event.Flag == code && event.Enabled == enabled })) > 0
+}
+
+// Filter returns the recorded evaluations that match fn.
+func (r *Recorder) Filter(fn func(Event) bool) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []Event
+	for _, event := range r.events {
+		if fn(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,56 @@
+package features
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	initFlags()
+
+	recorder := NewRecorder(DefaultClient, 0)
+	start := time.Now()
+
+	require.True(t, Flag("global-enabled"))
+	require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+	require.False(t, Flag("tenant-disabled", WithTenant("foo-tenant")))
+	require.False(t, Flag("global-disabled", WithTenant("bar-tenant"), WithUser("foo-user")))
+
+	recorder.Close()
+	require.True(t, Flag("global-enabled"))
+
+	require.Len(t, recorder.Evaluations(), 4)
+	require.Len(t, recorder.Flag("global-enabled"), 1)
+	require.Len(t, recorder.Tenant("foo-tenant"), 2)
+	require.Len(t, recorder.Since(start), 4)
+	require.Empty(t, recorder.Since(time.Now()))
+	require.True(t, recorder.Evaluated("tenant-enabled", true))
+	require.False(t, recorder.Evaluated("tenant-enabled", false))
+
+	events := recorder.Flag("global-disabled")
+	require.Len(t, events, 1)
+	require.Equal(t, "bar-tenant", events[0].Tenant)
+	require.Equal(t, "foo-user", events[0].User)
+	require.Equal(t, ReasonGlobal, events[0].Reason)
+
+	recorder.Reset()
+	require.Empty(t, recorder.Evaluations())
+}
+
+func TestRecorderMax(t *testing.T) {
+	initFlags()
+
+	recorder := NewRecorder(DefaultClient, 2)
+	defer recorder.Close()
+
+	require.True(t, Flag("global-enabled"))
+	require.False(t, Flag("global-disabled"))
+	require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+
+	events := recorder.Evaluations()
+	require.Len(t, events, 2)
+	require.Equal(t, "global-disabled", events[0].Flag)
+	require.Equal(t, "tenant-enabled", events[1].Flag)
+}
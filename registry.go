@@ -0,0 +1,56 @@
+package features
+
+import (
+	"slices"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   []string
+	defaults   = make(map[string]bool)
+)
+
+// Register declares the flags used by the service. When there are declared flags
+// the server only returns those, shrinking the payload, and it can report which
+// services reference each flag. It is usually called from package level variables
+// or init functions before Configure:
+//
+//	func init() {
+//		features.Register("new-checkout", "new-header")
+//	}
+func Register(codes ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, code := range codes {
+		if !slices.Contains(registry, code) {
+			registry = append(registry, code)
+		}
+	}
+	slices.Sort(registry)
+}
+
+// Define declares a flag like Register and configures the value it should have when
+// it is unknown, either because the server does not have it or because the flags
+// were not fetched yet. Kill switches can default to enabled to be safe.
+func Define(code string, def bool) {
+	Register(code)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defaults[code] = def
+}
+
+func definedDefault(code string) (def bool, ok bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok = defaults[code]
+	return def, ok
+}
+
+func registeredFlags() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Clone(registry)
+}
@@ -0,0 +1,66 @@
+package features
+
+import (
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resetRegistry() {
+	registry = nil
+	defaults = make(map[string]bool)
+}
+
+func TestRegisterSendsDeclaredFlags(t *testing.T) {
+	t.Cleanup(resetRegistry)
+
+	synctest.Test(t, func(t *testing.T) {
+		Register("global-enabled", "tenant-enabled")
+		Register("global-enabled")
+
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		require.Equal(t, "foo-project", tr.getQuery().Get("project"))
+		require.Equal(t, []string{"global-enabled", "tenant-enabled"}, tr.getQuery()["flag"])
+	})
+}
+
+func TestRegisterWithoutDeclaredFlags(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initFetch(0)
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		require.NotContains(t, tr.getQuery(), "flag")
+	})
+}
+
+func TestDefineDefaults(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	initFlags()
+
+	Define("kill-switch", true)
+	Define("global-disabled", true)
+
+	require.True(t, Flag("kill-switch"))
+	require.False(t, Flag("global-disabled"))
+	require.False(t, Flag("not-found"))
+	require.Equal(t, []string{"global-disabled", "kill-switch"}, registeredFlags())
+}
+
+func TestFailOpen(t *testing.T) {
+	t.Cleanup(resetRegistry)
+	initFlags()
+	DefaultClient.failOpen = true
+
+	Define("not-found-disabled", false)
+
+	require.True(t, Flag("not-found"))
+	require.False(t, Flag("not-found-disabled"))
+	require.False(t, Flag("global-disabled"))
+	require.False(t, NewSnapshot("").Flag("not-found-disabled"))
+	require.True(t, NewSnapshot("").Flag("not-found"))
+}
@@ -0,0 +1,36 @@
+package features
+
+import (
+	"fmt"
+)
+
+// ErrorReporter receives the failures of the client fetching flags or sending stats,
+// to surface them in an error tracking system.
+type ErrorReporter interface {
+	ReportError(err error)
+}
+
+// ErrorReporterFunc adapts a function to the ErrorReporter interface.
+type ErrorReporterFunc func(err error)
+
+// ReportError calls the function.
+func (fn ErrorReporterFunc) ReportError(err error) {
+	fn(err)
+}
+
+// SentryReporter reports the errors to Sentry with the capture function of the SDK,
+// usually sentry.CaptureException or the one of a specific hub:
+//
+//	features.Configure(serverURL, project, features.WithErrorReporter(features.SentryReporter(sentry.CaptureException)))
+func SentryReporter[T any](capture func(err error) T) ErrorReporter {
+	return ErrorReporterFunc(func(err error) {
+		capture(err)
+	})
+}
+
+func (c *Client) reportError(format string, err error) {
+	if c.reporter == nil {
+		return
+	}
+	c.reporter.ReportError(fmt.Errorf(format, err))
+}
@@ -0,0 +1,51 @@
+package features
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReporter struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *fakeReporter) ReportError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func TestErrorReporterFetch(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		initFetch(4 * time.Second)
+		defer DefaultClient.Close()
+
+		reporter := new(fakeReporter)
+		DefaultClient.reporter = reporter
+
+		require.False(t, Flag("global-enabled"))
+
+		reporter.mu.Lock()
+		defer reporter.mu.Unlock()
+		require.Len(t, reporter.errs, 1)
+		require.Contains(t, reporter.errs[0].Error(), "feature flags: fetch failed")
+	})
+}
+
+func TestSentryReporter(t *testing.T) {
+	type eventID string
+	var captured error
+	reporter := SentryReporter(func(err error) *eventID {
+		captured = err
+		return nil
+	})
+
+	reporter.ReportError(errors.New("foo error"))
+	require.EqualError(t, captured, "foo error")
+}
@@ -0,0 +1,58 @@
+package features
+
+import (
+	"log/slog"
+)
+
+type healthSignal int
+
+const (
+	healthNone healthSignal = iota
+	healthOK
+	healthFailed
+)
+
+// ReportHealth reports a health signal of the code behind the flag with the default
+// client, like the result of an operation that runs with the flag enabled. The
+// signals are aggregated and sent with the stats, so the server can roll back the
+// flag automatically when they degrade.
+func ReportHealth(flag string, ok bool) {
+	if DefaultClient == nil {
+		return
+	}
+	DefaultClient.ReportHealth(flag, ok)
+}
+
+// ReportHealth reports a health signal of the code behind the flag. See the package
+// function ReportHealth for details.
+func (c *Client) ReportHealth(flag string, ok bool) {
+	if c.local {
+		return
+	}
+
+	health := healthFailed
+	if ok {
+		health = healthOK
+	}
+	select {
+	case c.statsCh <- accessEvent{flag: flag, health: health}:
+	default:
+		c.logger.Debug("feature flags: stats access channel full, dropping health signal", slog.String("flag", flag))
+	}
+}
+
+// warnKilled logs the flags disabled in an emergency by the server since the
+// previous fetch.
+func (c *Client) warnKilled(previous, fetched []flagReply) {
+	killed := make(map[string]bool)
+	for _, f := range previous {
+		if f.Killed {
+			killed[f.Code] = true
+		}
+	}
+	for _, f := range fetched {
+		if f.Killed && !killed[f.Code] {
+			c.logger.Warn("feature flags: flag killed by the server", slog.String("flag", f.Code))
+		}
+	}
+}
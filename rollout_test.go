@@ -0,0 +1,57 @@
+package features
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/synctest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportHealth(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		ReportHealth("global-enabled", true)
+		ReportHealth("global-enabled", true)
+		ReportHealth("global-enabled", false)
+		synctest.Wait()
+		require.NoError(t, DefaultClient.Close())
+
+		require.Len(t, tr.sent, 1)
+		require.EqualValues(t, 1, tr.sent[0].TotalHits)
+		require.EqualValues(t, 2, tr.sent[0].HealthyReports)
+		require.EqualValues(t, 1, tr.sent[0].UnhealthyReports)
+	})
+}
+
+func TestKilledFlags(t *testing.T) {
+	initFlags()
+	frozen := true
+	DefaultClient.flags = []flagReply{
+		{Code: "killed", Enabled: true, Killed: true, Users: []flagTenant{{Code: "foo-user", Enabled: true}}},
+		{Code: "killed-frozen", Killed: true, Frozen: &frozen},
+		{Code: "killed-percentage", Enabled: true, Killed: true, Value: json.RawMessage(`50`)},
+	}
+
+	require.Equal(t, FlagDetail{Reason: ReasonKilled, Layer: LayerServer}, Detail("killed", WithUser("foo-user")))
+	require.Equal(t, FlagDetail{Reason: ReasonKilled, Layer: LayerServer}, Detail("killed-frozen"))
+	require.Zero(t, Percentage("killed-percentage", 10))
+}
+
+func TestWarnKilled(t *testing.T) {
+	var buf bytes.Buffer
+	c := &Client{logger: slog.New(slog.NewTextHandler(&buf, nil))}
+
+	c.warnKilled(
+		[]flagReply{{Code: "already-killed", Killed: true}, {Code: "new-killed"}},
+		[]flagReply{{Code: "already-killed", Killed: true}, {Code: "new-killed", Killed: true}, {Code: "alive"}},
+	)
+	require.Equal(t, 1, strings.Count(buf.String(), "flag killed"))
+	require.Contains(t, buf.String(), "flag=new-killed")
+}
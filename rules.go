@@ -0,0 +1,140 @@
+package features
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Rules with fewer values are faster to search again than to memoize.
+	memoRuleValues = 32
+
+	memoRuleTTL     = time.Minute
+	memoRuleEntries = 10000
+)
+
+// matchRule returns the first rule whose attribute has one of its values.
+func matchRule(rules []flagRule, attributes map[string]string) (flagRule, bool) {
+	for _, rule := range rules {
+		value, ok := attributes[rule.Attribute]
+		if ok && slices.Contains(rule.Values, value) {
+			return rule, true
+		}
+	}
+	return flagRule{}, false
+}
+
+func equalRule(a, b flagRule) bool {
+	return a.Attribute == b.Attribute && a.Enabled == b.Enabled && slices.Equal(a.Values, b.Values)
+}
+
+// attributesKey encodes the attributes in a stable order to cache the evaluations.
+// Names and values are prefixed with their length, so any character can appear in
+// them without making two different sets of attributes share the same key.
+func attributesKey(attributes map[string]string) string {
+	var key strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		value := attributes[name]
+		key.WriteString(strconv.Itoa(len(name)) + ":" + name + strconv.Itoa(len(value)) + ":" + value)
+	}
+	return key.String()
+}
+
+// ruleMemo caches the rule matched by each context in the flags with many values,
+// so the hot paths that evaluate the same attributes millions of times do not search
+// the rules again. The results are keyed by the flag of the payload, so the flags of
+// a new payload never reuse them, and they are reset when a new payload is stored to
+// release the previous one.
+type ruleMemo struct {
+	mu      sync.Mutex
+	results map[ruleKey]ruleResult
+}
+
+type ruleKey struct {
+	flag    *flagReply
+	context string
+}
+
+type ruleResult struct {
+	rule    flagRule
+	matched bool
+	expires time.Time
+}
+
+func newRuleMemo() *ruleMemo {
+	return &ruleMemo{
+		results: make(map[ruleKey]ruleResult),
+	}
+}
+
+// reset discards the results of the previous payloads. A nil memo has nothing to
+// reset.
+func (memo *ruleMemo) reset() {
+	if memo == nil {
+		return
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	clear(memo.results)
+}
+
+// match returns the first rule of the flag that matches the attributes. A nil memo
+// searches the rules every time.
+func (memo *ruleMemo) match(f *flagReply, attributes map[string]string) (flagRule, bool) {
+	if memo == nil || ruleValues(f.Rules) < memoRuleValues {
+		return matchRule(f.Rules, attributes)
+	}
+
+	key := ruleKey{flag: f, context: ruleContext(f.Rules, attributes)}
+	now := time.Now()
+	memo.mu.Lock()
+	result, ok := memo.results[key]
+	memo.mu.Unlock()
+	if ok && now.Before(result.expires) {
+		return result.rule, result.matched
+	}
+
+	rule, matched := matchRule(f.Rules, attributes)
+
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	if len(memo.results) >= memoRuleEntries {
+		for k, r := range memo.results {
+			if !now.Before(r.expires) {
+				delete(memo.results, k)
+			}
+		}
+		// Contexts that never repeat, like unique identifiers, restart the memo.
+		if len(memo.results) >= memoRuleEntries {
+			clear(memo.results)
+		}
+	}
+	memo.results[key] = ruleResult{rule: rule, matched: matched, expires: now.Add(memoRuleTTL)}
+	return rule, matched
+}
+
+func ruleValues(rules []flagRule) int {
+	var n int
+	for _, rule := range rules {
+		n += len(rule.Values)
+	}
+	return n
+}
+
+// ruleContext encodes only the attributes checked by the rules, so the contexts
+// that differ in other attributes share the result.
+func ruleContext(rules []flagRule, attributes map[string]string) string {
+	var key strings.Builder
+	for _, rule := range rules {
+		// The length prefix keeps the values apart whatever they contain.
+		if value, ok := attributes[rule.Attribute]; ok {
+			key.WriteString(strconv.Itoa(len(value)) + ":" + value)
+		}
+		key.WriteByte(';')
+	}
+	return key.String()
+}
@@ -0,0 +1,69 @@
+package features
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedFetches coalesces the fetches of the clients of the same process that target
+// the same server and project, so multiple clients do not multiply the requests.
+var sharedFetches = struct {
+	sf singleflight.Group
+
+	mu     sync.Mutex
+	recent map[string]sharedResult
+}{
+	recent: make(map[string]sharedResult),
+}
+
+type sharedResult struct {
+	payload   sourcePayload
+	fetchedAt time.Time
+}
+
+// sharedFetch runs fetch only once for all the clients requesting the same URL at the
+// same time, and reuses the result for the clients that request it again inside the
+// window. Only clients with the default HTTP client, that already share the
+// connections, and the same credentials and duplicate policy share the results.
+func sharedFetch(c *Client, evalURL string, window time.Duration, fetch func() (sourcePayload, error)) (sourcePayload, error) {
+	if c.client != http.DefaultClient || c.idToken != nil {
+		return fetch()
+	}
+	// The URL contains the project, so the fetches of different projects never wait
+	// for each other. The duplicate policy is applied while fetching, so clients with
+	// different policies cannot reuse their results.
+	key := fmt.Sprintf("%s %d %s", c.apiKey, c.duplicatePolicy, evalURL)
+
+	sharedFetches.mu.Lock()
+	recent, ok := sharedFetches.recent[key]
+	sharedFetches.mu.Unlock()
+	if ok && time.Since(recent.fetchedAt) < window {
+		return recent.payload, nil
+	}
+
+	result, err, _ := sharedFetches.sf.Do(key, func() (any, error) {
+		payload, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		sharedFetches.mu.Lock()
+		defer sharedFetches.mu.Unlock()
+		for k, r := range sharedFetches.recent {
+			if time.Since(r.fetchedAt) >= window {
+				delete(sharedFetches.recent, k)
+			}
+		}
+		sharedFetches.recent[key] = sharedResult{payload, time.Now()}
+
+		return payload, nil
+	})
+	if err != nil {
+		return sourcePayload{}, err
+	}
+	return result.(sourcePayload), nil
+}
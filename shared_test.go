@@ -0,0 +1,96 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedFetch(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveFlags(w, r)
+	}))
+	defer server.Close()
+
+	first := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer first.Close()
+	second := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer second.Close()
+	other := NewClient(server.URL, "other-project", WithLocal(false), WithDisableStats(true))
+	defer other.Close()
+
+	require.NoError(t, first.WaitForReady(t.Context()))
+	require.NoError(t, second.WaitForReady(t.Context()))
+	require.NoError(t, other.WaitForReady(t.Context()))
+
+	require.True(t, second.IsEnabled("global-enabled", ""))
+	require.EqualValues(t, 2, requests.Load())
+}
+
+func TestSharedFetchCrossProjectStale(t *testing.T) {
+	var blocking atomic.Bool
+	release := make(chan struct{})
+	var foo, other atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("project") == "foo-project" {
+			foo.Add(1)
+			if blocking.Load() {
+				<-release
+			}
+		} else {
+			other.Add(1)
+		}
+		serveFlags(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	first := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithMaxStaleness(time.Hour))
+	defer first.Close()
+	second := NewClient(server.URL, "other-project", WithLocal(false), WithDisableStats(true), WithMaxStaleness(time.Hour))
+	defer second.Close()
+	require.NoError(t, first.WaitForReady(t.Context()))
+	require.NoError(t, second.WaitForReady(t.Context()))
+
+	// Mark both clients as stale without reusing the results of the previous fetches.
+	blocking.Store(true)
+	for _, client := range []*Client{first, second} {
+		client.mu.Lock()
+		client.stale = time.Now().Add(-time.Second)
+		client.mu.Unlock()
+		client.maxFetchInterval = 0
+	}
+
+	// The stale fetch of the other project finishes while the first one is still blocked.
+	require.True(t, first.IsEnabled("global-enabled", ""))
+	require.Eventually(t, func() bool { return foo.Load() == 2 }, time.Second, 10*time.Millisecond)
+	require.True(t, second.IsEnabled("global-enabled", ""))
+	require.Eventually(t, func() bool { return other.Load() == 2 && !second.isStale() }, time.Second, 10*time.Millisecond)
+	require.True(t, first.isStale())
+}
+
+func TestSharedFetchDuplicatePolicy(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`[{"code":"foo","enabled":false},{"code":"foo","enabled":true}]`))
+	}))
+	defer server.Close()
+
+	lenient := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithDuplicatePolicy(DuplicateLastWins))
+	defer lenient.Close()
+	require.NoError(t, lenient.WaitForReady(t.Context()))
+
+	strict := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer strict.Close()
+	require.False(t, strict.IsEnabled("foo", ""))
+	require.EqualValues(t, 2, requests.Load())
+	require.False(t, strict.Ready())
+	require.True(t, lenient.IsEnabled("foo", ""))
+}
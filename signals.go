@@ -0,0 +1,77 @@
+package features
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+type SignalOption func(*signalOptions)
+
+type signalOptions struct {
+	noRaise bool
+}
+
+// WithoutRaise does not raise the signal again after closing the client. Use it when
+// the application handles SIGTERM or SIGINT itself with signal.Notify: its handlers
+// already receive the signal, and raising it again would deliver it twice.
+func WithoutRaise() SignalOption {
+	return func(o *signalOptions) {
+		o.noRaise = true
+	}
+}
+
+// HandleSignals closes the default client when the process receives SIGTERM or
+// SIGINT, flushing the pending stats and stopping the background goroutines.
+//
+// By default the signal is raised again after closing the client, so a process
+// without other handlers keeps its usual shutdown behavior and exits. Applications
+// that handle the signals themselves should pass WithoutRaise, or their handlers
+// receive each signal twice.
+//
+// The returned function uninstalls the handler. It can be called multiple times.
+func HandleSignals(opts ...SignalOption) (stop func()) {
+	o := new(signalOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGTERM, os.Interrupt)
+
+	go func() {
+		select {
+		case sig := <-ch:
+			signal.Stop(ch)
+			if DefaultClient != nil {
+				DefaultClient.Close()
+			}
+			if !o.noRaise {
+				raise(sig)
+			}
+
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		// Platforms that cannot deliver the signal to themselves exit directly.
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package features
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSignals(t *testing.T) {
+	// Keep the test process alive when the signal is raised again.
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGTERM)
+	defer signal.Stop(ch)
+
+	DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+	stop := HandleSignals()
+	defer stop()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	for range 2 {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("signal not received")
+		}
+	}
+	require.Error(t, DefaultClient.ctx.Err())
+}
+
+func TestHandleSignalsWithoutRaise(t *testing.T) {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGTERM)
+	defer signal.Stop(ch)
+
+	DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+	stop := HandleSignals(WithoutRaise())
+	defer stop()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not received")
+	}
+	require.Eventually(t, func() bool { return DefaultClient.ctx.Err() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	// The signal was not raised again.
+	select {
+	case <-ch:
+		t.Fatal("signal received twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleSignalsStopTwice(t *testing.T) {
+	stop := HandleSignals()
+	stop()
+	stop()
+}
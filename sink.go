@@ -0,0 +1,107 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Event is a single evaluation of a flag.
+type Event struct {
+	Time    time.Time
+	Project string
+	Flag    string
+	Tenant  string
+	User    string
+	Enabled bool
+	Reason  Reason
+}
+
+// Sink receives the raw evaluation events, for analysis that the aggregated stats
+// per minute cannot support. Events are sent in batches from a background goroutine.
+type Sink interface {
+	Send(ctx context.Context, events []Event) error
+}
+
+const (
+	maxSinkBatch    = 1000
+	sinkFlushPeriod = 5 * time.Second
+)
+
+// emitEvent notifies the evaluation listeners and queues the evaluation for the
+// sink, if configured. Events are dropped if the sink cannot keep up instead of
+// blocking the evaluations.
+func (c *Client) emitEvent(flag, tenant, user string, detail FlagDetail) {
+	listeners := c.evaluations.load()
+	if c.sink == nil && len(listeners) == 0 {
+		return
+	}
+
+	event := Event{
+		Time:    time.Now(),
+		Project: c.project,
+		Flag:    flag,
+		Tenant:  tenant,
+		User:    user,
+		Enabled: detail.Enabled,
+		Reason:  detail.Reason,
+	}
+	for _, fn := range listeners {
+		fn(event)
+	}
+	if c.sink == nil {
+		return
+	}
+
+	select {
+	case c.sinkCh <- event:
+	default:
+		c.logger.Debug("feature flags: sink channel full, dropping event", slog.String("flag", flag))
+	}
+}
+
+func (c *Client) backgroundSink() {
+	t := time.NewTicker(sinkFlushPeriod)
+	defer t.Stop()
+
+	var batch []Event
+	flush := func(ctx context.Context) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		err := c.sink.Send(ctx, batch)
+		if err != nil {
+			c.logger.Error("feature flags: failed to send events to the sink", slog.String("error", err.Error()), slog.Int("events", len(batch)))
+			c.reportError("feature flags: failed to send events to the sink: %w", err)
+			err = fmt.Errorf("cannot send %d events to the sink: %w", len(batch), err)
+		}
+		batch = nil
+		return err
+	}
+
+	for {
+		select {
+		case <-t.C:
+			_ = flush(c.ctx)
+
+		case event := <-c.sinkCh:
+			batch = append(batch, event)
+			if len(batch) >= maxSinkBatch {
+				_ = flush(c.ctx)
+			}
+
+		case <-c.ctx.Done():
+			// Drain the events queued before closing.
+			for len(c.sinkCh) > 0 {
+				batch = append(batch, <-c.sinkCh)
+			}
+			if err := flush(context.Background()); err != nil {
+				c.closeError(err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package features
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]Event
+}
+
+func (s *fakeSink) Send(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, events)
+	return nil
+}
+
+func (s *fakeSink) getBatches() [][]Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestSink(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		sink := new(fakeSink)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true), WithSink(sink))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: new(fakeEval)}
+
+		require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+		require.False(t, Flag("global-disabled"))
+
+		time.Sleep(sinkFlushPeriod)
+		synctest.Wait()
+		require.Equal(t, [][]Event{{
+			{Time: time.Now().Add(-sinkFlushPeriod), Project: "foo-project", Flag: "tenant-enabled", Tenant: "foo-tenant", Enabled: true, Reason: ReasonTenant},
+			{Time: time.Now().Add(-sinkFlushPeriod), Project: "foo-project", Flag: "global-disabled", Reason: ReasonGlobal},
+		}}, sink.getBatches())
+
+		// Pending events are sent when closing.
+		require.True(t, Flag("global-enabled"))
+		DefaultClient.Close()
+		require.Len(t, sink.getBatches(), 2)
+	})
+}
+
+func TestClickHouseSink(t *testing.T) {
+	var query, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	defer server.Close()
+
+	sink := NewClickHouseSink(server.URL, "features.evaluations")
+	err := sink.Send(t.Context(), []Event{
+		{Time: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), Project: "foo", Flag: "bar", Tenant: "baz", Enabled: true, Reason: ReasonTenant},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "INSERT INTO features.evaluations FORMAT JSONEachRow", query)
+	require.Equal(t, `{"time":"2025-06-01 10:00:00.000","project":"foo","flag":"bar","tenant":"baz","enabled":true,"reason":"TENANT"}`, strings.TrimSpace(body))
+}
+
+func TestClickHouseSinkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. Table does not exist", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sink := NewClickHouseSink(server.URL, "features.evaluations")
+	err := sink.Send(t.Context(), []Event{{Flag: "bar"}})
+	require.ErrorContains(t, err, "Table does not exist")
+}
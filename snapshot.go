@@ -0,0 +1,159 @@
+package features
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/altipla-consulting/env"
+)
+
+// Snapshot is a consistent view of the flags for a single tenant. It is usually
+// created once per request so every evaluation inside it sees the same values even
+// if a background fetch updates the flags in the middle of the request.
+//
+// Evaluations are memoized, repeating them only reads a small map and registers
+// the stats of the flag once for each snapshot.
+type Snapshot struct {
+	client   *Client
+	tenant   string
+	flags    []flagReply
+	volatile map[string]bool
+	stale    bool
+
+	mu   sync.Mutex
+	memo map[string]FlagDetail
+}
+
+// NewSnapshot captures the current state of the flags of the default client for
+// the tenant. An empty tenant only evaluates global flags.
+func NewSnapshot(tenant string) *Snapshot {
+	// Uninitialized client is considered as a basic development snapshot.
+	if DefaultClient == nil {
+		return &Snapshot{tenant: tenant}
+	}
+	return DefaultClient.Snapshot(tenant)
+}
+
+// Snapshot captures the current state of the flags for the tenant.
+func (c *Client) Snapshot(tenant string) *Snapshot {
+	snap := &Snapshot{
+		client: c,
+		tenant: tenant,
+	}
+	if c.local {
+		return snap
+	}
+
+	c.access()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	flags, fresh := c.usableFlags()
+	snap.flags = flags
+	snap.volatile = c.volatile
+	snap.stale = !fresh
+
+	return snap
+}
+
+// Tenant returns the tenant of the snapshot.
+func (snap *Snapshot) Tenant() string {
+	return snap.tenant
+}
+
+// Flag returns true if the flag is enabled in the snapshot.
+func (snap *Snapshot) Flag(code string) bool {
+	return snap.Detail(code).Enabled
+}
+
+// Detail evaluates the flag in the snapshot and returns the result with the reason
+// that explains it.
+func (snap *Snapshot) Detail(code string) FlagDetail {
+	if snap.client == nil {
+		return FlagDetail{Enabled: env.IsLocal(), Reason: ReasonUnconfigured}
+	}
+
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+	if detail, ok := snap.memo[code]; ok {
+		return detail
+	}
+	if snap.memo == nil {
+		snap.memo = make(map[string]FlagDetail)
+	}
+	detail := snap.evaluate(code)
+	snap.memo[code] = detail
+	return detail
+}
+
+func (snap *Snapshot) evaluate(code string) FlagDetail {
+	snap.client.accessVolatile(snap.volatile, code)
+	detail, track := snap.resolve(code)
+	if track {
+		snap.client.trackAccess(code, "", detail.Enabled)
+	}
+	snap.client.emitEvent(code, snap.tenant, "", detail)
+	return detail
+}
+
+// resolve evaluates the flag in the snapshot without registering it. It reports if
+// the evaluation should be counted in the stats.
+func (snap *Snapshot) resolve(code string) (FlagDetail, bool) {
+	if detail, ok := snap.client.override(code, snap.tenant); ok {
+		return detail, true
+	}
+	if snap.client.local {
+		return FlagDetail{Enabled: true, Reason: ReasonLocal}, false
+	}
+
+	detail := snap.client.evaluate(snap.flags, code, snap.tenant, "", nil)
+	if snap.stale {
+		detail.Reason = ReasonStale
+	}
+	return detail, detail.Reason != ReasonFrozen
+}
+
+// evaluated returns a copy of the flags evaluated in the snapshot with their result.
+func (snap *Snapshot) evaluated() map[string]FlagDetail {
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+	return maps.Clone(snap.memo)
+}
+
+// enabledFlags returns the codes of all the known flags enabled in the snapshot
+// without registering any stats. Known flags are the same ones of AllFlags.
+func (snap *Snapshot) enabledFlags() []string {
+	if snap.client == nil {
+		return nil
+	}
+
+	known := registeredFlags()
+	for _, f := range snap.flags {
+		known = append(known, f.Code)
+	}
+	known = append(known, snap.client.overriddenFlags()...)
+	slices.Sort(known)
+
+	var codes []string
+	for _, code := range slices.Compact(known) {
+		if detail, _ := snap.resolve(code); detail.Enabled {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+type snapshotKey struct{}
+
+// NewContext returns a child context that carries the snapshot.
+func NewContext(ctx context.Context, snap *Snapshot) context.Context {
+	return context.WithValue(ctx, snapshotKey{}, snap)
+}
+
+// FromContext returns the snapshot stored in the context, or nil if there is none.
+func FromContext(ctx context.Context) *Snapshot {
+	snap, _ := ctx.Value(snapshotKey{}).(*Snapshot)
+	return snap
+}
@@ -0,0 +1,85 @@
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	initFlags()
+
+	snap := NewSnapshot("foo-tenant")
+	require.Equal(t, "foo-tenant", snap.Tenant())
+	require.True(t, snap.Flag("global-enabled"))
+	require.True(t, snap.Flag("tenant-enabled"))
+	require.False(t, snap.Flag("tenant-disabled"))
+	require.False(t, snap.Flag("not-found"))
+}
+
+func TestSnapshotConsistent(t *testing.T) {
+	initFlags()
+
+	snap := NewSnapshot("")
+	DefaultClient.flags = []flagReply{
+		{Code: "global-enabled", Enabled: false},
+	}
+
+	require.True(t, snap.Flag("global-enabled"))
+	require.False(t, Flag("global-enabled"))
+}
+
+func TestSnapshotContext(t *testing.T) {
+	initFlags()
+
+	require.Nil(t, FromContext(context.Background()))
+
+	snap := NewSnapshot("foo-tenant")
+	ctx := NewContext(context.Background(), snap)
+	require.Equal(t, snap, FromContext(ctx))
+}
+
+func TestSnapshotMemoized(t *testing.T) {
+	initFlags()
+
+	snap := NewSnapshot("")
+	require.True(t, snap.Flag("global-enabled"))
+
+	DefaultClient.Override("global-enabled", false)
+	defer DefaultClient.ResetOverrides()
+
+	require.True(t, snap.Flag("global-enabled"))
+	require.False(t, NewSnapshot("").Flag("global-enabled"))
+}
+
+func TestFlagWithContextSnapshot(t *testing.T) {
+	initFlags()
+
+	ctx := NewContext(context.Background(), NewSnapshot("foo-tenant"))
+	DefaultClient.flags = []flagReply{
+		{Code: "tenant-enabled", Enabled: false},
+	}
+
+	require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+	require.False(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("other-tenant")))
+	require.False(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+}
+
+func TestFlagWithContextSnapshotAttributes(t *testing.T) {
+	initFlags()
+
+	ctx := NewContext(context.Background(), NewSnapshot("foo-tenant"))
+	DefaultClient.flags = []flagReply{
+		{
+			Code:    "tenant-enabled",
+			Enabled: true,
+			Tenants: []flagTenant{{Code: "foo-tenant", Enabled: true}},
+			Rules:   []flagRule{{Attribute: "country", Values: []string{"ES"}, Enabled: false}},
+		},
+	}
+
+	require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+	require.Equal(t, ReasonAttribute, Detail("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant"), WithAttribute("country", "ES")).Reason)
+	require.False(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant"), WithAttribute("country", "ES")))
+}
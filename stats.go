@@ -2,123 +2,409 @@ package features
 
 import (
 	"bytes"
+	"cmp"
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 )
 
 type accessEvent struct {
 	flag    string
+	user    string
 	enabled bool
+	health  healthSignal // health signals are not evaluations
 }
 
-func (c *featuresClient) trackAccess(flag string, enabled bool) {
+func (c *Client) trackAccess(flag, user string, enabled bool) {
+	if c.metrics != nil {
+		c.metrics.countEvaluation(flag, enabled)
+	}
+
 	select {
-	case c.statsCh <- accessEvent{flag: flag, enabled: enabled}:
+	case c.statsCh <- accessEvent{flag: flag, user: user, enabled: enabled}:
 	default:
 		c.logger.Debug("feature flags: stats access channel full, dropping event", slog.String("flag", flag))
 	}
 }
 
-func (c *featuresClient) backgroundStats() {
-	c.logger.Info("feature flags: background stats collector enabled")
+const (
+	minStatsBackoff = 5 * time.Second
+	maxStatsBackoff = 5 * time.Minute
+)
 
-	defer c.wg.Done()
+func (c *Client) backgroundStats() {
+	c.logger.Info("feature flags: background stats collector enabled")
 
 	t := time.NewTicker(1 * time.Minute)
 	defer t.Stop()
 
+	// After a failure the stats are sent again with an exponential backoff. The retry
+	// timer allows sending them sooner than the next tick.
+	var backoff time.Duration
+	var retry <-chan time.Time
+
+	// Stats are posted from their own goroutine so a slow server does not stall the
+	// collection of the events. Only one send is in flight at the same time.
+	var sending chan statsResult
+	send := func() {
+		if sending != nil {
+			return
+		}
+
+		// Discard stats older than the retention that could not be sent in time.
+		c.cleanupStats(c.statsNow().Add(-c.statsRetention))
+
+		if c.local || c.discardDisabledStats() || (len(c.stats) == 0 && len(c.statsPending) == 0) {
+			backoff = 0
+			retry = nil
+			return
+		}
+
+		c.logger.Debug("feature flags: sending stats")
+		c.batchStats()
+		batches := c.statsPending
+		c.statsPending = nil
+
+		ch := make(chan statsResult, 1)
+		started := c.goBackground("stats-sender", func() {
+			ch <- c.postBatches(c.ctx, batches)
+		})
+		if !started {
+			// The client is closing, the final flush will send them.
+			c.statsPending = batches
+			return
+		}
+		sending = ch
+	}
+	receive := func(result statsResult) {
+		sending = nil
+
+		// Failed batches are older than anything batched while they were in flight.
+		c.statsPending = append(result.failed, c.statsPending...)
+		c.statsEntries -= result.sent
+	}
+
 	for {
 		select {
 		case <-t.C:
-			if err := c.sendStats(c.ctx); err != nil {
-				c.logger.Error("feature flags: failed to send stats", slog.String("error", err.Error()))
-
-				// Cleanup stats older than 20 hours.
-				cutoff := time.Now().Add(-20 * time.Hour).UnixMilli()
-				for flag, flagStats := range c.stats {
-					for bucket := range flagStats.buckets {
-						if bucket < cutoff {
-							delete(flagStats.buckets, bucket)
-						}
-					}
-					if len(flagStats.buckets) == 0 {
-						delete(c.stats, flag)
-					}
-				}
+			// Wait for the retry timer instead if we are backing off.
+			if retry != nil {
+				continue
 			}
+			send()
 
-		case event := <-c.statsCh:
-			stats, ok := c.stats[event.flag]
-			if !ok {
-				stats = &flagStats{
-					buckets: make(map[int64]*bucketStats),
+		case <-retry:
+			send()
+
+		case result := <-sending:
+			receive(result)
+			if result.err != nil {
+				if backoff == 0 {
+					backoff = minStatsBackoff
+				} else {
+					backoff = min(backoff*2, maxStatsBackoff)
 				}
-				c.stats[event.flag] = stats
+				retry = time.After(backoff)
+				c.logger.Error("feature flags: failed to send stats", slog.String("error", result.err.Error()), slog.Duration("retry", backoff))
+				c.reportError("feature flags: failed to send stats: %w", result.err)
+				continue
 			}
+			backoff = 0
+			retry = nil
 
-			key := time.Now().Truncate(time.Minute).UnixMilli()
-			bucket, ok := stats.buckets[key]
-			if !ok {
-				bucket = new(bucketStats)
-				stats.buckets[key] = bucket
-			}
+		case event := <-c.statsCh:
+			c.collectStat(event)
 
-			bucket.totalHits++
-			if event.enabled {
-				bucket.enabledHits++
+		case batches := <-c.statsHandoff:
+			c.statsPending = append(c.statsPending, batches...)
+			for _, batch := range batches {
+				c.statsEntries += len(batch.stats)
 			}
 
 		case <-c.ctx.Done():
+			// The send in flight is canceled with the context and its batches are
+			// retried below.
+			if sending != nil {
+				receive(<-sending)
+			}
+			for len(c.statsCh) > 0 {
+				c.collectStat(<-c.statsCh)
+			}
+
+			// The pending stats are moved to the next client in a handoff. They are
+			// batched here, while the mirror is still open.
+			if c.skipFinalStats.Load() {
+				c.batchStats()
+				return
+			}
 			if err := c.sendStats(context.Background()); err != nil {
 				c.logger.Error("feature flags: failed to send stats on context done", slog.String("error", err.Error()))
+				c.reportError("feature flags: failed to send stats on context done: %w", err)
+				c.closeError(fmt.Errorf("cannot send %d stats: %w", c.statsEntries, err))
 			}
 			return
 		}
 	}
 }
 
+// discardDisabledStats discards the collected stats if they are disabled at the time
+// of the flush by the function configured with WithStatsEnabledFunc.
+func (c *Client) discardDisabledStats() bool {
+	if c.statsEnabled == nil || c.statsEnabled() {
+		return false
+	}
+	if c.statsEntries > 0 {
+		c.logger.Debug("feature flags: stats disabled, discarding them", slog.Int("entries", c.statsEntries))
+	}
+	clear(c.stats)
+	c.statsPending = nil
+	c.statsEntries = 0
+	return true
+}
+
+func (c *Client) collectStat(event accessEvent) {
+	stats, ok := c.stats[event.flag]
+	if !ok {
+		stats = &flagStats{
+			buckets: make(map[bucketKey]*bucketStats),
+		}
+		c.stats[event.flag] = stats
+	}
+
+	key := bucketKey{
+		bucket: c.statsNow().Truncate(time.Minute).UnixMilli(),
+		user:   event.user,
+	}
+	bucket, ok := stats.buckets[key]
+	if !ok {
+		// Limit the memory retained while the stats cannot be sent dropping the oldest data.
+		if c.statsEntries >= c.maxStatsEntries {
+			c.dropOldestStat()
+		}
+
+		bucket = new(bucketStats)
+		stats.buckets[key] = bucket
+		c.statsEntries++
+	}
+
+	switch event.health {
+	case healthOK:
+		bucket.healthyReports++
+	case healthFailed:
+		bucket.unhealthyReports++
+	default:
+		bucket.totalHits++
+		c.rates.count(event.flag, key.bucket)
+		if event.enabled {
+			bucket.enabledHits++
+		}
+	}
+}
+
+// statsNow returns the time of the stats buckets, corrected with the clock of the
+// server if configured so hosts with drifted clocks do not distort the dashboards.
+func (c *Client) statsNow() time.Time {
+	return time.Now().Add(time.Duration(c.clockSkew.Load()))
+}
+
+// cleanupStats removes the buckets older than the cutoff.
+func (c *Client) cleanupStats(cutoff time.Time) {
+	for flag, flagStats := range c.stats {
+		for key := range flagStats.buckets {
+			if key.bucket < cutoff.UnixMilli() {
+				delete(flagStats.buckets, key)
+				c.statsEntries--
+			}
+		}
+		if len(flagStats.buckets) == 0 {
+			delete(c.stats, flag)
+		}
+	}
+
+	for i := range c.statsPending {
+		batch := &c.statsPending[i]
+		before := len(batch.stats)
+		batch.stats = slices.DeleteFunc(batch.stats, func(entry StatEntry) bool {
+			return entry.Bucket < cutoff.UnixMilli()
+		})
+		c.statsEntries -= before - len(batch.stats)
+	}
+	c.statsPending = slices.DeleteFunc(c.statsPending, func(batch statsBatch) bool {
+		return len(batch.stats) == 0
+	})
+}
+
+func (c *Client) dropOldestStat() {
+	// Pending batches always contain older data than the stats still being collected.
+	if len(c.statsPending) > 0 {
+		batch := &c.statsPending[0]
+		oldest := slices.MinFunc(batch.stats, func(a, b StatEntry) int {
+			return cmp.Compare(a.Bucket, b.Bucket)
+		})
+		c.logger.Warn("feature flags: too many pending stats, dropping the oldest bucket", slog.String("flag", oldest.Flag), slog.Int64("bucket", oldest.Bucket))
+		batch.stats = slices.DeleteFunc(batch.stats, func(entry StatEntry) bool {
+			return entry == oldest
+		})
+		if len(batch.stats) == 0 {
+			c.statsPending = c.statsPending[1:]
+		}
+		c.statsEntries--
+		return
+	}
+
+	var oldestFlag string
+	var oldest bucketKey
+	for flag, flagStats := range c.stats {
+		for key := range flagStats.buckets {
+			if oldestFlag == "" || key.bucket < oldest.bucket {
+				oldestFlag = flag
+				oldest = key
+			}
+		}
+	}
+	if oldestFlag == "" {
+		return
+	}
+
+	c.logger.Warn("feature flags: too many pending stats, dropping the oldest bucket", slog.String("flag", oldestFlag), slog.Int64("bucket", oldest.bucket))
+	delete(c.stats[oldestFlag].buckets, oldest)
+	if len(c.stats[oldestFlag].buckets) == 0 {
+		delete(c.stats, oldestFlag)
+	}
+	c.statsEntries--
+}
+
 type flagStats struct {
-	buckets map[int64]*bucketStats
+	buckets map[bucketKey]*bucketStats
+}
+
+// bucketKey identifies the stats of a minute, split by the user of the evaluations.
+type bucketKey struct {
+	bucket int64
+	user   string
 }
 
 type bucketStats struct {
-	enabledHits int64
-	totalHits   int64
+	enabledHits      int64
+	totalHits        int64
+	healthyReports   int64
+	unhealthyReports int64
 }
 
-func (c *featuresClient) sendStats(ctx context.Context) error {
-	if c.local {
+// statsBatch is a chunk of stats ready to be sent. The idempotency key is kept
+// between retries so the server can deduplicate requests that timed out after
+// being processed.
+type statsBatch struct {
+	key   string
+	stats []StatEntry
+}
+
+func (c *Client) sendStats(ctx context.Context) error {
+	if c.local || c.discardDisabledStats() {
 		return nil
 	}
 
-	if len(c.stats) == 0 {
+	if len(c.stats) == 0 && len(c.statsPending) == 0 {
 		return nil
 	}
 
 	c.logger.Debug("feature flags: sending stats")
+	c.batchStats()
+
+	result := c.postBatches(ctx, c.statsPending)
+	c.statsPending = result.failed
+	c.statsEntries -= result.sent
+
+	return result.err
+}
+
+type statsResult struct {
+	failed []statsBatch
+	sent   int
+	err    error
+}
 
-	var stats []statEntry
+// postBatches sends the batches and returns the ones that failed. Only the
+// acknowledged batches are discarded. It does not modify the state of the client, so
+// it can run outside of the stats collector.
+func (c *Client) postBatches(ctx context.Context, batches []statsBatch) statsResult {
+	var result statsResult
+	var errs []error
+	for _, batch := range batches {
+		if err := c.postStats(ctx, batch); err != nil {
+			errs = append(errs, err)
+			result.failed = append(result.failed, batch)
+			continue
+		}
+		result.sent += len(batch.stats)
+	}
+	result.err = errors.Join(errs...)
+
+	return result
+}
+
+// batchStats moves the collected stats to new pending batches. Big payloads are sent
+// in chunks so a single rejected request does not block the rest of the stats.
+func (c *Client) batchStats() {
+	c.mu.RLock()
+	archived := make(map[string]bool)
+	for _, f := range c.flags {
+		if f.Archived {
+			archived[f.Code] = true
+		}
+	}
+	c.mu.RUnlock()
+
+	var stats []StatEntry
 	for flag, flagStats := range c.stats {
-		for bucket, bucketStats := range flagStats.buckets {
-			stats = append(stats, statEntry{
-				Bucket:      bucket,
+		for key, bucketStats := range flagStats.buckets {
+			stats = append(stats, StatEntry{
+				Bucket:      key.bucket,
 				Flag:        flag,
 				EnabledHits: bucketStats.enabledHits,
 				TotalHits:   bucketStats.totalHits,
+				User:        key.user,
+				Archived:    archived[flag],
+
+				HealthyReports:   bucketStats.healthyReports,
+				UnhealthyReports: bucketStats.unhealthyReports,
 			})
 		}
 	}
+	for chunk := range slices.Chunk(stats, c.maxStatsChunk) {
+		batch := statsBatch{
+			key:   newUUID(),
+			stats: chunk,
+		}
+		c.mirrorStats(batch)
+		c.statsPending = append(c.statsPending, batch)
+	}
+	c.stats = make(map[string]*flagStats)
+}
 
-	var buf bytes.Buffer
-	in := statsRequest{
-		Project: c.project,
-		Stats:   stats,
+func (c *Client) newStatsRequest(batch statsBatch) StatsRequest {
+	return StatsRequest{
+		Project:    c.project,
+		InstanceID: c.instanceID,
+		Hostname:   c.hostname,
+		Region:     c.region,
+		Service:    c.service,
+		Version:    c.version,
+		Revision:   c.revision,
+		Stats:      batch.stats,
+		Scope:      c.scope,
 	}
-	if err := json.NewEncoder(&buf).Encode(in); err != nil {
+}
+
+func (c *Client) postStats(ctx context.Context, batch statsBatch) error {
+	encoder := c.statsEncoder()
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, c.newStatsRequest(batch)); err != nil {
 		return fmt.Errorf("failed to marshal stats: %w", err)
 	}
 
@@ -129,19 +415,38 @@ func (c *featuresClient) sendStats(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("cannot create stats request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", encoder.ContentType())
+	req.Header.Set("Idempotency-Key", batch.key)
+	if err := c.authorizeStats(req); err != nil {
+		return err
+	}
 
+	if err := c.injectFault(ctx, faultStats); err != nil {
+		return fmt.Errorf("cannot send stats: %w", err)
+	}
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("cannot send stats: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnsupportedMediaType && encoder.ContentType() != (jsonStatsEncoder{}).ContentType() {
+		c.logger.Warn("feature flags: server does not accept the stats encoding, falling back to JSON", slog.String("content-type", encoder.ContentType()))
+		c.encoderRejected.Store(true)
+		return c.postStats(ctx, batch)
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("unexpected stats status code %d", resp.StatusCode)
 	}
 
-	c.stats = make(map[string]*flagStats)
-
 	return nil
 }
+
+// newUUID generates a random UUID version 4.
+func newUUID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
@@ -5,7 +5,13 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/synctest"
 	"time"
@@ -14,26 +20,51 @@ import (
 )
 
 type fakeStats struct {
-	forceError bool
-	last       *statsRequest
+	forceError atomic.Bool
+	rejectFlag string
+	block      chan struct{}
+	skew       time.Duration
+	last       *StatsRequest
+	sent       []StatEntry
+	keys       []string
+	evals      atomic.Int32
 }
 
 func (c *fakeStats) RoundTrip(req *http.Request) (*http.Response, error) {
 	if req.URL.Path == "/stats" {
-		if c.forceError {
+		if req.Header.Get("Content-Type") != "application/json" {
+			return &http.Response{StatusCode: http.StatusUnsupportedMediaType, Body: http.NoBody}, nil
+		}
+		c.keys = append(c.keys, req.Header.Get("Idempotency-Key"))
+		if c.block != nil {
+			<-c.block
+		}
+		if c.forceError.Load() {
 			return nil, fmt.Errorf("forced error")
 		}
 
-		c.last = new(statsRequest)
-		if err := json.NewDecoder(req.Body).Decode(c.last); err != nil {
+		in := new(StatsRequest)
+		if err := json.NewDecoder(req.Body).Decode(in); err != nil {
 			return nil, err
 		}
+		for _, stat := range in.Stats {
+			if stat.Flag == c.rejectFlag {
+				return &http.Response{StatusCode: http.StatusRequestEntityTooLarge, Body: http.NoBody}, nil
+			}
+		}
+		c.last = in
+		c.sent = append(c.sent, in.Stats...)
 
 		return &http.Response{StatusCode: http.StatusNoContent}, nil
 	}
 
 	if req.URL.Path == "/eval" {
-		return (new(fakeEval)).RoundTrip(req)
+		c.evals.Add(1)
+		resp, err := (new(fakeEval)).RoundTrip(req)
+		if err == nil && c.skew != 0 {
+			resp.Header = http.Header{"Date": {time.Now().Add(c.skew).Format(http.TimeFormat)}}
+		}
+		return resp, err
 	}
 
 	return &http.Response{StatusCode: http.StatusNotFound}, nil
@@ -43,9 +74,7 @@ func initStats() *fakeStats {
 	tr := new(fakeStats)
 
 	slog.SetLogLoggerLevel(slog.LevelDebug)
-	DefaultClient = newClient("https://example.com", "foo-project", &configureOptions{
-		logger: slog.Default(),
-	})
+	DefaultClient = NewClient("https://example.com", "foo-project", WithLogger(slog.Default()), WithHostname("foo-host"), WithRegion("europe-west1"))
 	DefaultClient.local = false
 	DefaultClient.client = &http.Client{Transport: tr}
 
@@ -63,6 +92,8 @@ func TestStats(t *testing.T) {
 		DefaultClient.Close()
 
 		require.Equal(t, "foo-project", tr.last.Project)
+		require.Equal(t, "foo-host", tr.last.Hostname)
+		require.Equal(t, "europe-west1", tr.last.Region)
 		require.Len(t, tr.last.Stats, 1)
 		require.Equal(t, "global-enabled", tr.last.Stats[0].Flag)
 		require.EqualValues(t, 946684800000, tr.last.Stats[0].Bucket)
@@ -98,31 +129,32 @@ func TestStatsMultipleFlagsMultipleMinutes(t *testing.T) {
 		tr := initStats()
 		defer DefaultClient.Close()
 
-		tr.forceError = true
+		tr.forceError.Store(true)
 
 		require.True(t, Flag("global-enabled"))
 		time.Sleep(90 * time.Second)
 		require.False(t, Flag("global-disabled"))
 
-		tr.forceError = false
+		tr.forceError.Store(false)
 
 		synctest.Wait()
 		DefaultClient.Close()
 
-		require.Len(t, tr.last.Stats, 2)
-		sort.Slice(tr.last.Stats, func(i, j int) bool {
-			return tr.last.Stats[i].Flag < tr.last.Stats[j].Flag
+		// Stats that failed are retried in their own request.
+		require.Len(t, tr.sent, 2)
+		sort.Slice(tr.sent, func(i, j int) bool {
+			return tr.sent[i].Flag < tr.sent[j].Flag
 		})
 
 		{
-			stat := tr.last.Stats[0]
+			stat := tr.sent[0]
 			require.Equal(t, "global-disabled", stat.Flag)
 			require.EqualValues(t, 946684860000, stat.Bucket)
 			require.EqualValues(t, 0, stat.EnabledHits)
 			require.EqualValues(t, 1, stat.TotalHits)
 		}
 		{
-			stat := tr.last.Stats[1]
+			stat := tr.sent[1]
 			require.Equal(t, "global-enabled", stat.Flag)
 			require.EqualValues(t, 946684800000, stat.Bucket)
 			require.EqualValues(t, 1, stat.EnabledHits)
@@ -159,3 +191,397 @@ func TestStatsCleanupAfterSuccess(t *testing.T) {
 		require.EqualValues(t, 1, tr.last.Stats[0].TotalHits)
 	})
 }
+
+func TestStatsRetryBackoff(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		tr.forceError.Store(true)
+		require.True(t, Flag("global-enabled"))
+
+		time.Sleep(61 * time.Second)
+		synctest.Wait()
+		require.Nil(t, tr.last)
+
+		tr.forceError.Store(false)
+		time.Sleep(5 * time.Second)
+		synctest.Wait()
+
+		require.NotNil(t, tr.last)
+		require.Len(t, tr.last.Stats, 1)
+		require.Equal(t, "global-enabled", tr.last.Stats[0].Flag)
+	})
+}
+
+func TestStatsMaxEntries(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		DefaultClient.maxStatsEntries = 2
+		tr.forceError.Store(true)
+
+		require.True(t, Flag("global-enabled"))
+		time.Sleep(1 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+		time.Sleep(1 * time.Minute)
+		require.True(t, Flag("global-enabled"))
+
+		tr.forceError.Store(false)
+
+		synctest.Wait()
+		DefaultClient.Close()
+
+		require.Len(t, tr.sent, 2)
+		sort.Slice(tr.sent, func(i, j int) bool {
+			return tr.sent[i].Bucket < tr.sent[j].Bucket
+		})
+		require.EqualValues(t, 946684860000, tr.sent[0].Bucket)
+		require.EqualValues(t, 946684920000, tr.sent[1].Bucket)
+	})
+}
+
+func TestStatsRetention(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		DefaultClient.statsRetention = 90 * time.Second
+		tr.forceError.Store(true)
+
+		require.True(t, Flag("global-enabled"))
+		time.Sleep(1 * time.Minute)
+		require.False(t, Flag("global-disabled"))
+		time.Sleep(1 * time.Minute)
+
+		tr.forceError.Store(false)
+		time.Sleep(1 * time.Minute)
+		synctest.Wait()
+
+		require.Len(t, tr.last.Stats, 1)
+		require.Equal(t, "global-disabled", tr.last.Stats[0].Flag)
+	})
+}
+
+func TestStatsChunks(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		DefaultClient.maxStatsChunk = 1
+		tr.rejectFlag = "global-disabled"
+
+		require.True(t, Flag("global-enabled"))
+		require.False(t, Flag("global-disabled"))
+		require.True(t, Flag("tenant-enabled", WithTenant("foo-tenant")))
+
+		synctest.Wait()
+		DefaultClient.Close()
+
+		require.Len(t, tr.sent, 2)
+		require.Len(t, DefaultClient.statsPending, 1)
+		require.Equal(t, "global-disabled", DefaultClient.statsPending[0].stats[0].Flag)
+	})
+}
+
+func TestStatsIdempotencyKey(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		tr.forceError.Store(true)
+		require.True(t, Flag("global-enabled"))
+
+		time.Sleep(66 * time.Second)
+		tr.forceError.Store(false)
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+
+		require.Len(t, tr.keys, 3)
+		require.NotEmpty(t, tr.keys[0])
+		require.Equal(t, tr.keys[0], tr.keys[1])
+		require.Equal(t, tr.keys[0], tr.keys[2])
+		require.Equal(t, DefaultClient.instanceID, tr.last.InstanceID)
+	})
+}
+
+func TestStatsCloseError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		tr.forceError.Store(true)
+		require.True(t, Flag("global-enabled"))
+		synctest.Wait()
+
+		err := DefaultClient.Close()
+		require.ErrorContains(t, err, "cannot send 1 stats")
+		require.ErrorContains(t, err, "forced error")
+		require.Equal(t, err, DefaultClient.Close())
+	})
+}
+
+func TestStatsSlowServer(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		tr.block = make(chan struct{})
+		require.True(t, Flag("global-enabled"))
+
+		time.Sleep(61 * time.Second)
+		synctest.Wait()
+		require.Len(t, tr.keys, 1)
+
+		// Events are still collected while the stats are being sent.
+		for range 3 {
+			require.False(t, Flag("global-disabled"))
+		}
+		synctest.Wait()
+		require.Empty(t, DefaultClient.statsCh)
+
+		close(tr.block)
+		synctest.Wait()
+		DefaultClient.Close()
+
+		sort.Slice(tr.sent, func(i, j int) bool {
+			return tr.sent[i].Flag < tr.sent[j].Flag
+		})
+		require.Equal(t, []StatEntry{
+			{Bucket: 946684860000, Flag: "global-disabled", EnabledHits: 0, TotalHits: 3},
+			{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 1, TotalHits: 1},
+		}, tr.sent)
+	})
+}
+
+func TestStatsArchived(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+		require.False(t, Flag("global-disabled"))
+		synctest.Wait()
+
+		DefaultClient.mu.Lock()
+		DefaultClient.flags = []flagReply{
+			{Code: "global-enabled", Enabled: true, Archived: true},
+			{Code: "global-disabled", Enabled: false},
+		}
+		DefaultClient.mu.Unlock()
+		require.NoError(t, DefaultClient.Close())
+
+		archived := make(map[string]bool)
+		for _, stat := range tr.sent {
+			archived[stat.Flag] = stat.Archived
+		}
+		require.Equal(t, map[string]bool{"global-enabled": true, "global-disabled": false}, archived)
+	})
+}
+
+func TestStatsUser(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled", WithUser("u1")))
+		require.True(t, Flag("global-enabled", WithUser("u1")))
+		require.True(t, Flag("global-enabled", WithUser("u2")))
+		require.True(t, Flag("global-enabled"))
+		synctest.Wait()
+		require.NoError(t, DefaultClient.Close())
+
+		sort.Slice(tr.sent, func(i, j int) bool {
+			return tr.sent[i].User < tr.sent[j].User
+		})
+		require.Equal(t, []StatEntry{
+			{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 1, TotalHits: 1},
+			{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 2, TotalHits: 2, User: "u1"},
+			{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 1, TotalHits: 1, User: "u2"},
+		}, tr.sent)
+	})
+}
+
+func TestStatsFrozen(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+
+		frozen := true
+		DefaultClient.mu.Lock()
+		DefaultClient.flags = append(DefaultClient.flags, flagReply{Code: "frozen", Frozen: &frozen})
+		DefaultClient.mu.Unlock()
+		require.True(t, Flag("frozen"))
+		require.True(t, DefaultClient.Snapshot("").Flag("frozen"))
+
+		synctest.Wait()
+		require.NoError(t, DefaultClient.Close())
+
+		require.Len(t, tr.sent, 1)
+		require.Equal(t, "global-enabled", tr.sent[0].Flag)
+	})
+}
+
+func TestStatsSeparateEndpoint(t *testing.T) {
+	var evalAuth, statsAuth, statsPath string
+	eval := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evalAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`[{"code": "foo", "enabled": true}]`))
+	}))
+	defer eval.Close()
+	ingest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statsAuth = r.Header.Get("Authorization")
+		statsPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ingest.Close()
+
+	client := NewClient(eval.URL, "foo-project", WithLocal(false), WithAPIKey("eval-key"), WithStatsURL(ingest.URL+"/ingest"), WithStatsAuth("stats-key"))
+	require.True(t, client.IsEnabled("foo", ""))
+	require.NoError(t, client.Close())
+
+	require.Equal(t, "Bearer eval-key", evalAuth)
+	require.Equal(t, "Bearer stats-key", statsAuth)
+	require.Equal(t, "/ingest", statsPath)
+}
+
+func TestStatsDisableFetch(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(fakeStats)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithLocal(false), WithDisableFetch(true), WithFailOpen(true))
+		DefaultClient.client = &http.Client{Transport: tr}
+		defer DefaultClient.Close()
+
+		require.True(t, Ready())
+		DefaultClient.Override("overridden", false)
+		require.False(t, Flag("overridden"))
+		require.True(t, Flag("default"))
+
+		time.Sleep(10 * time.Minute)
+		synctest.Wait()
+		require.NoError(t, DefaultClient.Close())
+
+		require.Zero(t, tr.evals.Load())
+		flags := make(map[string]int64)
+		for _, stat := range tr.sent {
+			flags[stat.Flag] += stat.TotalHits
+		}
+		require.Equal(t, map[string]int64{"overridden": 1, "default": 1}, flags)
+	})
+}
+
+func TestStatsEnabledFunc(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := initStats()
+		defer DefaultClient.Close()
+
+		var enabled atomic.Bool
+		DefaultClient.statsEnabled = enabled.Load
+
+		require.True(t, Flag("global-enabled"))
+		time.Sleep(61 * time.Second)
+		synctest.Wait()
+		require.Empty(t, tr.sent)
+
+		enabled.Store(true)
+		require.False(t, Flag("global-disabled"))
+		time.Sleep(61 * time.Second)
+		synctest.Wait()
+		require.Len(t, tr.sent, 1)
+		require.Equal(t, "global-disabled", tr.sent[0].Flag)
+	})
+}
+
+func TestStatsServerClock(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := &fakeStats{skew: 2 * time.Hour}
+		DefaultClient = NewClient("https://example.com", "foo-project", WithServerClock(true))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+
+		synctest.Wait()
+		DefaultClient.Close()
+
+		require.Len(t, tr.last.Stats, 1)
+		require.EqualValues(t, 946692000000, tr.last.Stats[0].Bucket)
+	})
+}
+
+func TestStatsEncoderFallback(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(fakeStats)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithStatsEncoder(NDJSONStatsEncoder()))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+		defer DefaultClient.Close()
+
+		require.True(t, Flag("global-enabled"))
+
+		synctest.Wait()
+		DefaultClient.Close()
+
+		require.Len(t, tr.sent, 1)
+		require.True(t, DefaultClient.encoderRejected.Load())
+	})
+}
+
+func TestStatsMirror(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+
+	synctest.Test(t, func(t *testing.T) {
+		tr := new(fakeStats)
+		DefaultClient = NewClient("https://example.com", "foo-project", WithStatsMirror(path))
+		DefaultClient.local = false
+		DefaultClient.client = &http.Client{Transport: tr}
+
+		tr.forceError.Store(true)
+		require.True(t, Flag("global-enabled"))
+
+		time.Sleep(66 * time.Second)
+		tr.forceError.Store(false)
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+
+		DefaultClient.Close()
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		require.Len(t, lines, 1)
+
+		var line statsMirrorLine
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+		require.Equal(t, tr.keys[0], line.Key)
+		require.Equal(t, "foo-project", line.Project)
+		require.Equal(t, []StatEntry{{Bucket: 946684800000, Flag: "global-enabled", EnabledHits: 1, TotalHits: 1}}, line.Stats)
+	})
+}
+
+func TestStatsScopeAndService(t *testing.T) {
+	var evalQuery url.Values
+	var stats StatsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stats" {
+			_ = json.NewDecoder(r.Body).Decode(&stats)
+			return
+		}
+		evalQuery = r.URL.Query()
+		serveFlags(w, r)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithScope(map[string]string{"cluster": "eu-1", "region": "europe"}), WithService("api-gateway"))
+	require.NoError(t, client.WaitForReady(t.Context()))
+	require.True(t, client.IsEnabled("global-enabled", ""))
+	require.NoError(t, client.Close())
+
+	require.Equal(t, url.Values{"project": {"foo-project"}, "service": {"api-gateway"}, "scope.cluster": {"eu-1"}, "scope.region": {"europe"}}, evalQuery)
+	require.Equal(t, "api-gateway", stats.Service)
+	require.Equal(t, map[string]string{"cluster": "eu-1", "region": "europe"}, stats.Scope)
+}
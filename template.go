@@ -0,0 +1,23 @@
+package features
+
+import (
+	"text/template"
+)
+
+// TemplateFuncs returns the template functions to evaluate flags with the snapshot.
+// It can be used with both text/template and html/template:
+//
+//	{{if feature "new-checkout"}}...{{end}}
+//
+// A nil snapshot, like the one of FromContext in a request without it, evaluates
+// every flag to false so the templates can still be rendered.
+func TemplateFuncs(snap *Snapshot) template.FuncMap {
+	if snap == nil {
+		return template.FuncMap{
+			"feature": func(string) bool { return false },
+		}
+	}
+	return template.FuncMap{
+		"feature": snap.Flag,
+	}
+}
@@ -0,0 +1,40 @@
+package features
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	initFlags()
+
+	tmpl := template.Must(template.New("test").Funcs(TemplateFuncs(NewSnapshot("foo-tenant"))).Parse(`{{if feature "tenant-enabled"}}enabled{{end}}{{if feature "global-disabled"}}disabled{{end}}`))
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	require.Equal(t, "enabled", buf.String())
+}
+
+func TestTemplateFuncsHTML(t *testing.T) {
+	initFlags()
+
+	tmpl := htmltemplate.Must(htmltemplate.New("test").Funcs(TemplateFuncs(NewSnapshot(""))).Parse(`{{if feature "global-enabled"}}<b>enabled</b>{{end}}`))
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	require.Equal(t, "<b>enabled</b>", buf.String())
+}
+
+func TestTemplateFuncsNilSnapshot(t *testing.T) {
+	initFlags()
+
+	tmpl := template.Must(template.New("test").Funcs(TemplateFuncs(nil)).Parse(`{{if feature "global-enabled"}}enabled{{else}}disabled{{end}}`))
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	require.Equal(t, "disabled", buf.String())
+}
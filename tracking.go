@@ -0,0 +1,58 @@
+package features
+
+import (
+	"context"
+	"sync"
+)
+
+type evaluationsKey struct{}
+
+type evaluations struct {
+	mu    sync.Mutex
+	flags map[string]bool
+}
+
+// TrackEvaluations returns a child context that records the result of every flag
+// evaluated with WithContext. Error reporters like Sentry can then attach the
+// recorded state to their events to show which code paths were enabled.
+func TrackEvaluations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, evaluationsKey{}, &evaluations{
+		flags: make(map[string]bool),
+	})
+}
+
+// Evaluations returns the flags evaluated inside a tracked context with their last
+// result. Tenant evaluations are keyed as "flag@tenant". The map can be attached
+// directly as a Sentry context:
+//
+//	sentry.CurrentHub().Scope().SetContext("features", features.Evaluations(ctx))
+func Evaluations(ctx context.Context) map[string]any {
+	evals, ok := ctx.Value(evaluationsKey{}).(*evaluations)
+	if !ok {
+		return nil
+	}
+
+	evals.mu.Lock()
+	defer evals.mu.Unlock()
+	result := make(map[string]any, len(evals.flags))
+	for key, enabled := range evals.flags {
+		result[key] = enabled
+	}
+	return result
+}
+
+func recordEvaluation(ctx context.Context, flag, tenant string, enabled bool) {
+	evals, ok := ctx.Value(evaluationsKey{}).(*evaluations)
+	if !ok {
+		return
+	}
+
+	key := flag
+	if tenant != "" {
+		key += "@" + tenant
+	}
+
+	evals.mu.Lock()
+	defer evals.mu.Unlock()
+	evals.flags[key] = enabled
+}
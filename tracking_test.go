@@ -0,0 +1,32 @@
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackEvaluations(t *testing.T) {
+	initFlags()
+
+	ctx := TrackEvaluations(context.Background())
+	require.True(t, Flag("global-enabled", WithContext(ctx)))
+	require.False(t, Flag("global-disabled", WithContext(ctx)))
+	require.True(t, Flag("tenant-enabled", WithContext(ctx), WithTenant("foo-tenant")))
+	require.True(t, Flag("global-enabled"))
+
+	require.Equal(t, map[string]any{
+		"global-enabled":            true,
+		"global-disabled":           false,
+		"tenant-enabled@foo-tenant": true,
+	}, Evaluations(ctx))
+}
+
+func TestTrackEvaluationsUntrackedContext(t *testing.T) {
+	initFlags()
+
+	ctx := context.Background()
+	require.True(t, Flag("global-enabled", WithContext(ctx)))
+	require.Nil(t, Evaluations(ctx))
+}
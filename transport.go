@@ -0,0 +1,122 @@
+package features
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unixHost is the host of the requests sent to a unix socket server.
+const unixHost = "unix"
+
+// parseUnixURL returns the URL of the requests and the path of the socket if the
+// server URL uses the unix scheme, like "unix:///run/features.sock".
+func parseUnixURL(serverURL string) (string, string) {
+	socket, ok := strings.CutPrefix(serverURL, "unix://")
+	if !ok {
+		return serverURL, ""
+	}
+	return "http://" + unixHost, socket
+}
+
+func newHTTPClient(opts *configureOptions, socket string) *http.Client {
+	custom := opts.dialContext != nil || socket != "" || opts.keepAlive != 0 || opts.maxIdleConns > 0 || opts.http2 != nil
+	if !custom {
+		return http.DefaultClient
+	}
+
+	// Same defaults as http.DefaultTransport.
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.keepAlive > 0 {
+		dialer.KeepAlive = opts.keepAlive
+		transport.IdleConnTimeout = opts.keepAlive
+	}
+	if opts.keepAlive < 0 {
+		dialer.KeepAlive = -1
+		transport.DisableKeepAlives = true
+	}
+	if opts.maxIdleConns > 0 {
+		transport.MaxIdleConns = opts.maxIdleConns
+		transport.MaxIdleConnsPerHost = opts.maxIdleConns
+	}
+	if opts.http2 != nil {
+		transport.ForceAttemptHTTP2 = *opts.http2
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+		transport.Protocols.SetHTTP2(*opts.http2)
+	}
+
+	dial := opts.dialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	if socket != "" {
+		next := dial
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == unixHost+":80" {
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+			return next(ctx, network, addr)
+		}
+	}
+	transport.DialContext = dial
+
+	return &http.Client{Transport: transport}
+}
+
+type hedgedResult struct {
+	resp *http.Response
+	err  error
+}
+
+// hedgedDo sends the request, and a second copy if the first one does not respond
+// before the hedging delay. It returns the first successful response and discards
+// the other one.
+func (c *Client) hedgedDo(req *http.Request) (*http.Response, error) {
+	if c.hedgeDelay <= 0 {
+		return c.client.Do(req)
+	}
+
+	results := make(chan hedgedResult, 2)
+	send := func() {
+		resp, err := c.client.Do(req.Clone(req.Context()))
+		results <- hedgedResult{resp, err}
+	}
+	go send()
+	inflight := 1
+
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			c.logger.Debug("feature flags: hedging slow request", slog.String("url", req.URL.String()))
+			go send()
+			inflight++
+
+		case result := <-results:
+			inflight--
+			if result.err != nil && inflight > 0 {
+				continue
+			}
+			if inflight > 0 {
+				// The other request is canceled with the context of the fetch.
+				go func() {
+					if other := <-results; other.resp != nil {
+						other.resp.Body.Close()
+					}
+				}()
+			}
+			return result.resp, result.err
+		}
+	}
+}
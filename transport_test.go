@@ -0,0 +1,98 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveFlags(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode([]flagReply{{Code: "global-enabled", Enabled: true}})
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "features.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(serveFlags)}
+	go func() { _ = server.Serve(lis) }()
+	defer server.Close()
+
+	client := NewClient("unix://"+socket, "foo-project", WithLocal(false), WithDisableStats(true))
+	defer client.Close()
+
+	require.NoError(t, client.WaitForReady(t.Context()))
+	require.True(t, client.IsEnabled("global-enabled", ""))
+}
+
+func TestDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(serveFlags))
+	defer server.Close()
+
+	var dials atomic.Int32
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return new(net.Dialer).DialContext(ctx, network, addr)
+	}
+	client := NewClient(server.URL, "foo-project", WithLocal(false), WithDisableStats(true), WithDialContext(dial))
+	defer client.Close()
+
+	require.NoError(t, client.WaitForReady(t.Context()))
+	require.True(t, client.IsEnabled("global-enabled", ""))
+	require.EqualValues(t, 1, dials.Load())
+}
+
+func TestHTTPClientTuning(t *testing.T) {
+	require.Equal(t, http.DefaultClient, newHTTPClient(newConfigureOptions(nil), ""))
+
+	client := newHTTPClient(newConfigureOptions([]ConfigureOption{
+		WithKeepAlive(2 * time.Minute),
+		WithMaxIdleConns(10),
+		WithHTTP2(false),
+	}), "")
+	transport := client.Transport.(*http.Transport)
+	require.Equal(t, 2*time.Minute, transport.IdleConnTimeout)
+	require.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	require.False(t, transport.Protocols.HTTP2())
+	require.True(t, transport.Protocols.HTTP1())
+}
+
+type fakeSlowFirst struct {
+	requests atomic.Int32
+}
+
+func (c *fakeSlowFirst) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c.requests.Add(1) == 1 {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return fakeSources{
+		"example.com": {{Code: "global-enabled", Enabled: true}},
+	}.RoundTrip(req)
+}
+
+func TestFetchHedging(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		client := NewClient("https://example.com", "foo-project", WithLocal(false), WithDisableStats(true), WithFetchHedging(500*time.Millisecond))
+		defer client.Close()
+		tr := new(fakeSlowFirst)
+		client.client = &http.Client{Transport: tr}
+
+		start := time.Now()
+		require.True(t, client.IsEnabled("global-enabled", ""))
+		require.Equal(t, 500*time.Millisecond, time.Since(start))
+		require.EqualValues(t, 2, tr.requests.Load())
+	})
+}
@@ -0,0 +1,118 @@
+package features
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Value returns the value of the flag from the default client decoded as T, to use
+// the flags for dynamic configuration like limits or timeouts. Durations are
+// configured in the server as strings like "1m30s". It returns def if the flag does
+// not exist, it is disabled for the tenant or user, or its value cannot be decoded
+// as T. The evaluations are counted in the stats like any other flag.
+func Value[T any](code string, def T, opts ...FlagOption) T {
+	if DefaultClient == nil {
+		return def
+	}
+	detail, flags := detailFlags(code, newFlagOptions(opts))
+	if !detail.Enabled {
+		return def
+	}
+	return decodeValue(DefaultClient.rawValue(flags, code), def)
+}
+
+// ClientValue returns the value of the flag from the client decoded as T. See Value
+// for the details.
+func ClientValue[T any](c *Client, code string, def T, opts ...FlagOption) T {
+	o := newFlagOptions(opts)
+	detail, flags := c.detailFlags(code, o.tenant, o.user, o.attributes)
+	if !detail.Enabled {
+		return def
+	}
+	return decodeValue(c.rawValue(flags, code), def)
+}
+
+// JSON decodes the value of the flag from the default client into out, that must be
+// a pointer, to ship structured configuration behind a flag. It returns false
+// without modifying out if the flag does not exist, it is disabled for the tenant or
+// user, or its value cannot be decoded.
+func JSON(code string, out any, opts ...FlagOption) bool {
+	if DefaultClient == nil {
+		return false
+	}
+	detail, flags := detailFlags(code, newFlagOptions(opts))
+	if !detail.Enabled {
+		return false
+	}
+	return decodeJSON(DefaultClient.rawValue(flags, code), out)
+}
+
+// JSON decodes the value of the flag into out, that must be a pointer. See JSON for
+// the details.
+func (c *Client) JSON(code string, out any, opts ...FlagOption) bool {
+	o := newFlagOptions(opts)
+	detail, flags := c.detailFlags(code, o.tenant, o.user, o.attributes)
+	if !detail.Enabled {
+		return false
+	}
+	return decodeJSON(c.rawValue(flags, code), out)
+}
+
+// rawValue returns the value of the flag in the flags of its evaluation. Overridden
+// and local flags are evaluated without the flags, so their value is read from the
+// flags that can be used right now.
+func (c *Client) rawValue(flags []flagReply, code string) json.RawMessage {
+	if flags == nil {
+		c.mu.RLock()
+		flags, _ = c.usableFlags()
+		c.mu.RUnlock()
+	}
+	for _, f := range flags {
+		if f.Code == code {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+func decodeValue[T any](raw json.RawMessage, def T) T {
+	if len(raw) == 0 {
+		return def
+	}
+
+	var value T
+	if d, ok := any(&value).(*time.Duration); ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return def
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return def
+		}
+		*d = parsed
+		return value
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return def
+	}
+	return value
+}
+
+// decodeJSON decodes the value in a new variable first, so out is not modified
+// partially when the value does not match its type.
+func decodeJSON(raw json.RawMessage, out any) bool {
+	target := reflect.ValueOf(out)
+	if len(raw) == 0 || target.Kind() != reflect.Pointer || target.IsNil() {
+		return false
+	}
+
+	decoded := reflect.New(target.Elem().Type())
+	if err := json.Unmarshal(raw, decoded.Interface()); err != nil {
+		return false
+	}
+	target.Elem().Set(decoded.Elem())
+	return true
+}
@@ -0,0 +1,113 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "max-items", Enabled: true, Value: json.RawMessage("25")},
+		{Code: "ratio", Enabled: true, Value: json.RawMessage("0.75")},
+		{Code: "theme", Enabled: true, Value: json.RawMessage(`"dark"`)},
+		{Code: "timeout", Enabled: true, Value: json.RawMessage(`"1m30s"`)},
+		{Code: "disabled", Enabled: false, Value: json.RawMessage("25")},
+		{Code: "boolean", Enabled: true},
+		{
+			Code:    "tenant-limit",
+			Enabled: true,
+			Value:   json.RawMessage("50"),
+			Tenants: []flagTenant{
+				{Code: "foo-tenant", Enabled: true},
+				{Code: "bar-tenant", Enabled: false},
+			},
+		},
+	}
+
+	require.Equal(t, 25, Value("max-items", 10))
+	require.Equal(t, 0.75, Value("ratio", 0.5))
+	require.Equal(t, "dark", Value("theme", "light"))
+	require.Equal(t, 90*time.Second, Value("timeout", time.Second))
+	require.Equal(t, 10, Value("disabled", 10))
+	require.Equal(t, 10, Value("boolean", 10))
+	require.Equal(t, 10, Value("not-found", 10))
+	require.Equal(t, 50, Value("tenant-limit", 10, WithTenant("foo-tenant")))
+	require.Equal(t, 10, Value("tenant-limit", 10, WithTenant("bar-tenant")))
+
+	// Values that cannot be decoded as the type of the default.
+	require.Equal(t, 10, Value("ratio", 10))
+	require.Equal(t, 10, Value("theme", 10))
+	require.Equal(t, time.Second, Value("max-items", time.Second))
+}
+
+func TestValueContextSnapshot(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{{Code: "max-items", Enabled: true, Value: json.RawMessage("25")}}
+	ctx := NewContext(context.Background(), NewSnapshot(""))
+
+	// A fetch in the middle of the request does not change the value of the snapshot.
+	DefaultClient.flags = []flagReply{{Code: "max-items", Enabled: false, Value: json.RawMessage("50")}}
+	require.Equal(t, 25, Value("max-items", 10, WithContext(ctx)))
+
+	var items int
+	require.True(t, JSON("max-items", &items, WithContext(ctx)))
+	require.Equal(t, 25, items)
+
+	require.Equal(t, 10, Value("max-items", 10))
+}
+
+func TestValueUnconfigured(t *testing.T) {
+	DefaultClient = nil
+	require.Equal(t, 10, Value("max-items", 10))
+}
+
+func TestClientValue(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "max-items", Enabled: true, Value: json.RawMessage("25")},
+	}
+
+	require.Equal(t, 25, ClientValue(DefaultClient, "max-items", 10))
+	require.Equal(t, "foo", ClientValue(DefaultClient, "max-items", "foo"))
+}
+
+func TestJSON(t *testing.T) {
+	initFlags()
+	DefaultClient.flags = []flagReply{
+		{Code: "limits", Enabled: true, Value: json.RawMessage(`{"requests":100,"endpoint":"https://example.com"}`)},
+		{Code: "invalid", Enabled: true, Value: json.RawMessage(`{"requests":"foo","endpoint":"https://other.example.com"}`)},
+		{Code: "disabled", Enabled: false, Value: json.RawMessage(`{"requests":5}`)},
+		{Code: "boolean", Enabled: true},
+	}
+
+	type limits struct {
+		Requests int    `json:"requests"`
+		Endpoint string `json:"endpoint"`
+	}
+	var out limits
+	require.True(t, JSON("limits", &out))
+	require.Equal(t, limits{Requests: 100, Endpoint: "https://example.com"}, out)
+
+	require.False(t, JSON("invalid", &out))
+	require.False(t, JSON("disabled", &out))
+	require.False(t, JSON("boolean", &out))
+	require.False(t, JSON("not-found", &out))
+	require.False(t, JSON("limits", out))
+	require.Equal(t, limits{Requests: 100, Endpoint: "https://example.com"}, out)
+
+	var client limits
+	require.True(t, DefaultClient.JSON("limits", &client))
+	require.Equal(t, out, client)
+}
+
+func TestJSONUnconfigured(t *testing.T) {
+	DefaultClient = nil
+	var out map[string]any
+	require.False(t, JSON("limits", &out))
+	require.Nil(t, out)
+}
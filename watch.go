@@ -0,0 +1,127 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// Watch calls fn with the current state of the flag for the default tenant, and
+// again each time the state changes. It runs in the goroutine of the caller the first
+// time, and in the background goroutine of the client after each fetch, so it should
+// return quickly. The returned function stops watching the flag.
+func Watch(code string, fn func(enabled bool)) (stop func()) {
+	if DefaultClient == nil {
+		return func() {}
+	}
+	return DefaultClient.Watch(code, fn)
+}
+
+// Watch calls fn with the current state of the flag for the default tenant of the
+// client, and again each time the state changes. See the package function Watch
+// for details.
+func (c *Client) Watch(code string, fn func(enabled bool)) (stop func()) {
+	var mu sync.Mutex
+	var notified, enabled bool
+	check := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		current := c.peek(code, c.defaultTenant)
+		if !notified || current != enabled {
+			fn(current)
+		}
+		notified, enabled = true, current
+	}
+
+	// The first fetch is not notified as a change.
+	done := make(chan struct{})
+	if !c.Ready() {
+		go func() {
+			select {
+			case <-c.ready:
+				check()
+			case <-done:
+			case <-c.ctx.Done():
+			}
+		}()
+	}
+
+	unregister := c.OnChange(func(Diff) { check() })
+	check()
+	return sync.OnceFunc(func() {
+		unregister()
+		close(done)
+	})
+}
+
+// peek evaluates the flag with the cached flags, without fetching them nor
+// recording the access in the stats. It is safe to call it from the change listeners.
+func (c *Client) peek(code, tenant string) bool {
+	if detail, ok := c.override(code, tenant); ok {
+		return detail.Enabled
+	}
+	if c.local {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	flags, _ := c.usableFlags()
+	return c.evaluate(flags, code, tenant, "", nil).Enabled
+}
+
+// RunWhileEnabled starts fn when the flag is enabled for the default tenant and
+// cancels its context when the flag is disabled. The worker starts again the next
+// time the flag is enabled. It blocks until ctx is done and the last worker returns.
+// Errors of the worker are logged and reported.
+func RunWhileEnabled(ctx context.Context, code string, fn func(ctx context.Context) error) {
+	if DefaultClient == nil {
+		<-ctx.Done()
+		return
+	}
+	DefaultClient.RunWhileEnabled(ctx, code, fn)
+}
+
+// RunWhileEnabled starts fn when the flag is enabled for the default tenant of the
+// client and cancels it when the flag is disabled. See the package function
+// RunWhileEnabled for details.
+func (c *Client) RunWhileEnabled(ctx context.Context, code string, fn func(ctx context.Context) error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var cancel context.CancelFunc
+	stopWorker := func() {
+		if cancel != nil {
+			cancel()
+			cancel = nil
+		}
+	}
+
+	stop := c.Watch(code, func(enabled bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !enabled || ctx.Err() != nil {
+			stopWorker()
+			return
+		}
+
+		var workerCtx context.Context
+		workerCtx, cancel = context.WithCancel(ctx)
+		wg.Go(func() {
+			if err := fn(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				c.logger.Error("feature flags: worker failed", slog.String("flag", code), slog.String("error", err.Error()))
+				c.reportError("feature flags: worker failed: %w", err)
+			}
+		})
+	})
+
+	<-ctx.Done()
+	stop()
+
+	mu.Lock()
+	stopWorker()
+	mu.Unlock()
+	wg.Wait()
+}
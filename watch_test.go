@@ -0,0 +1,90 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{
+			"example.com": {{Code: "foo", Enabled: true}},
+		}
+		DefaultClient.client = &http.Client{Transport: sources}
+
+		var states []bool
+		stop := Watch("foo", func(enabled bool) { states = append(states, enabled) })
+		defer stop()
+		require.Equal(t, []bool{false}, states)
+
+		DefaultClient.fetch()
+		synctest.Wait()
+		require.Equal(t, []bool{false, true}, states)
+
+		// Changes of other flags are not notified.
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}, {Code: "bar", Enabled: true}}
+		DefaultClient.fetch()
+		require.Equal(t, []bool{false, true}, states)
+
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: false}}
+		DefaultClient.fetch()
+		require.Equal(t, []bool{false, true, false}, states)
+	})
+}
+
+func TestRunWhileEnabled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		DefaultClient = NewClient("https://example.com", "foo-project", WithDisableStats(true))
+		defer DefaultClient.Close()
+		DefaultClient.local = false
+
+		sources := fakeSources{
+			"example.com": {{Code: "foo", Enabled: true}},
+		}
+		DefaultClient.client = &http.Client{Transport: sources}
+		DefaultClient.fetch()
+
+		var starts, stops int
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			RunWhileEnabled(ctx, "foo", func(ctx context.Context) error {
+				starts++
+				<-ctx.Done()
+				stops++
+				return ctx.Err()
+			})
+		}()
+		synctest.Wait()
+		require.Equal(t, 1, starts)
+		require.Zero(t, stops)
+
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: false}}
+		DefaultClient.fetch()
+		synctest.Wait()
+		require.Equal(t, 1, stops)
+
+		time.Sleep(DefaultClient.maxFetchInterval)
+		sources["example.com"] = []flagReply{{Code: "foo", Enabled: true}}
+		DefaultClient.fetch()
+		synctest.Wait()
+		require.Equal(t, 2, starts)
+
+		cancel()
+		<-done
+		require.Equal(t, 2, stops)
+	})
+}